@@ -8,21 +8,34 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sptodo/metrics"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 const (
-	dataDir    = "data"
-	usersFile  = "users.json"
-	SessionTTl = 24 * time.Hour
+	dataDir         = "data"
+	usersFile       = "users.json"
+	SessionTTl      = 24 * time.Hour
+	cleanupInterval = time.Minute
+)
+
+var (
+	ErrEmptyCredentials   = errors.New("Логин и пароль обязательны")
+	ErrUserExists         = errors.New("Пользователь с таким логином уже существует")
+	ErrInvalidCredentials = errors.New("Неверный логин или пароль")
+	ErrUserNotFound       = errors.New("Пользователь не найден")
+	ErrNoSession          = errors.New("Сессия не найдена")
+	ErrSessionExpired     = errors.New("Сессия истекла")
 )
 
 type User struct {
 	Login        string `json:"login"`
 	PasswordHash []byte `json:"password_hash"`
+	Lang         string `json:"lang,omitempty"` // язык интерфейса; пусто — по Accept-Language
 }
 
 type Session struct {
@@ -34,6 +47,8 @@ type Auth struct {
 	mu       sync.RWMutex
 	sessions map[string]*Session
 	users    map[string]*User
+
+	lastCleanup atomic.Int64 // unix-время последнего прохода cleanupSessions
 }
 
 // Создаем систему авторизации
@@ -53,6 +68,7 @@ func NewAuth() (*Auth, error) {
 		}
 	}
 
+	a.lastCleanup.Store(time.Now().Unix())
 	go a.cleanupSessions() //Очистка просроченных сессий
 
 	return a, nil
@@ -93,12 +109,12 @@ func (a *Auth) GetLogin(sessionID string) (string, error) {
 
 	session, ok := a.sessions[sessionID]
 	if !ok {
-		return "", errors.New("No session")
+		return "", ErrNoSession
 	}
 
 	if time.Now().After(session.Expiry) {
 		delete(a.sessions, sessionID)
-		return "", errors.New("Сессия истекла")
+		return "", ErrSessionExpired
 	}
 
 	return session.Login, nil
@@ -106,14 +122,14 @@ func (a *Auth) GetLogin(sessionID string) (string, error) {
 
 func (a *Auth) Register(login, password string) error {
 	if login == "" || password == "" {
-		return errors.New("Логин и пароль обязательны")
+		return ErrEmptyCredentials
 	}
 
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	if _, exists := a.users[login]; exists {
-		return errors.New("Пользователь с таким логином уже существует")
+		return ErrUserExists
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -135,11 +151,11 @@ func (a *Auth) Login(login, password string) (string, error) {
 	a.mu.Unlock()
 
 	if !exists {
-		return "", errors.New("Неверный логин или пароль")
+		return "", ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		return "", errors.New("Неверный логин или пароль")
+		return "", ErrInvalidCredentials
 	}
 
 	return a.CreateSession(login)
@@ -151,7 +167,7 @@ func (a *Auth) DeleteUser(login string) error {
 	defer a.mu.Unlock()
 
 	if _, exists := a.users[login]; !exists {
-		return errors.New("пользователь не найден")
+		return ErrUserNotFound
 	}
 
 	userDir := filepath.Join(dataDir, login)
@@ -164,6 +180,8 @@ func (a *Auth) DeleteUser(login string) error {
 }
 
 func (a *Auth) loadUsers() error {
+	defer metrics.StorageTimer("users", "read")()
+
 	data, err := os.ReadFile(filepath.Join(dataDir, usersFile))
 	if err != nil {
 		return err
@@ -182,6 +200,8 @@ func (a *Auth) loadUsers() error {
 }
 
 func (a *Auth) saveUsers() error {
+	defer metrics.StorageTimer("users", "write")()
+
 	var users []*User
 	for _, u := range a.users {
 		users = append(users, u)
@@ -196,10 +216,11 @@ func (a *Auth) saveUsers() error {
 }
 
 func (a *Auth) cleanupSessions() {
-	ticker := time.NewTicker(time.Minute)
+	ticker := time.NewTicker(cleanupInterval)
 	defer ticker.Stop()
 
 	for range ticker.C {
+		a.lastCleanup.Store(time.Now().Unix())
 		a.mu.Lock()
 		now := time.Now()
 		for id, session := range a.sessions {
@@ -221,3 +242,99 @@ func (a *Auth) ClearUserSessions(login string) {
 		}
 	}
 }
+
+// Количество активных (не истёкших) сессий
+func (a *Auth) SessionCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range a.sessions {
+		if now.Before(session.Expiry) {
+			count++
+		}
+	}
+	return count
+}
+
+func (a *Auth) UserCount() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return len(a.users)
+}
+
+// Логины всех зарегистрированных пользователей
+func (a *Auth) Logins() []string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	logins := make([]string, 0, len(a.users))
+	for login := range a.users {
+		logins = append(logins, login)
+	}
+	return logins
+}
+
+// Проверка, что в папку с данными можно писать
+func (a *Auth) CheckDataDir() error {
+	file, err := os.CreateTemp(dataDir, ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
+
+// Проверка, что файл пользователей читается и разбирается.
+// Под a.mu: saveUsers перезаписывает файл, и без блокировки можно прочитать его наполовину.
+func (a *Auth) CheckUsersFile() error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(dataDir, usersFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // ещё никто не зарегистрировался
+		}
+		return err
+	}
+
+	var users []User
+	return json.Unmarshal(data, &users)
+}
+
+// Проверка, что фоновая очистка сессий не остановилась
+func (a *Auth) CheckCleanup() error {
+	last := time.Unix(a.lastCleanup.Load(), 0)
+	if time.Since(last) > 2*cleanupInterval {
+		return fmt.Errorf("очистка сессий не запускалась с %s", last.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Язык, выбранный пользователем; пустая строка, если не выбирал
+func (a *Auth) UserLang(login string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if user, ok := a.users[login]; ok {
+		return user.Lang
+	}
+	return ""
+}
+
+func (a *Auth) SetUserLang(login, lang string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[login]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.Lang = lang
+
+	return a.saveUsers()
+}
@@ -4,4 +4,4 @@ go 1.24.0
 
 toolchain go1.24.9
 
-require golang.org/x/crypto v0.43.0 // indirect
+require golang.org/x/crypto v0.43.0
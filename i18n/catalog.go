@@ -0,0 +1,419 @@
+package i18n
+
+// Ключи error.* использует сервер, остальные — веб-интерфейс
+var catalog = map[string]map[string]string{
+	RU: {
+		"error.internal":             "Внутренняя ошибка сервера",
+		"error.unauthorized":         "Требуется вход",
+		"error.token_required":       "Требуется токен",
+		"error.route_not_found":      "Адрес не найден",
+		"error.method_not_allowed":   "Этот HTTP-метод здесь не поддерживается",
+		"error.invalid_json":         "Неверный JSON",
+		"error.json_required":        "Тело запроса должно быть в формате JSON",
+		"error.invalid_id":           "Неверный ID",
+		"error.invalid_language":     "Неподдерживаемый язык",
+		"error.todo_title_required":  "Заголовок задачи не может быть пустым",
+		"error.note_title_required":  "Заголовок обязателен",
+		"error.todo_not_found":       "Задача не найдена",
+		"error.subtask_not_found":    "Подзадача не найдена",
+		"error.list_not_found":       "Список не найден",
+		"error.list_archived":        "Список в архиве, задачи в него не добавляются",
+		"error.tag_not_found":        "Тег не найден",
+		"error.invalid_cursor":       "Неверный курсор страницы",
+		"error.query_required":       "Введите строку поиска",
+		"error.revision_not_found":   "Версия заметки не найдена",
+		"error.checkbox_not_found":   "В заметке нет такого пункта-чекбокса",
+		"error.not_in_trash":         "В корзине этого нет",
+		"error.no_attachment":        "Вложение не найдено",
+		"error.not_linked":           "Задача и заметка не связаны",
+		"error.folder_not_found":     "Папка не найдена",
+		"error.folder_cycle":         "Папку нельзя перенести внутрь неё самой",
+		"error.template_not_found":   "Шаблон не найден",
+		"error.already_linked":       "Задача и заметка уже связаны",
+		"error.quota_exceeded":       "Превышена квота на вложения",
+		"error.multipart_required":   "Файл нужно отправить как multipart/form-data",
+		"error.file_required":        "Нужен файл в поле file",
+		"error.if_match_required":    "Нужен заголовок If-Match с версией из ETag",
+		"error.precondition_failed":  "Запись уже изменили в другом месте — обновите её и повторите",
+		"error.tag_exists":           "Тег с таким названием уже есть",
+		"error.inbox_protected":      "Список «Входящие» нельзя удалить или архивировать",
+		"error.invalid_color":        "Цвет должен быть в формате #rrggbb",
+		"error.name_required":        "Название обязательно",
+		"error.subtask_too_deep":     "Слишком глубокая вложенность подзадач",
+		"error.invalid_due":          "Неверный формат срока: ожидается ГГГГ-ММ-ДД или дата со временем",
+		"error.invalid_reminder":     "Неверный формат времени напоминания",
+		"error.invalid_timezone":     "Неизвестный часовой пояс",
+		"error.invalid_filter":       "Неизвестное значение фильтра",
+		"error.invalid_recurrence":   "Неверное правило повторения",
+		"error.invalid_priority":     "Неизвестный приоритет",
+		"error.invalid_order":        "Порядок задач изменился, обновите список",
+		"error.note_not_found":       "Заметка не найдена",
+		"error.user_not_found":       "Пользователь не найден",
+		"error.credentials_required": "Логин и пароль обязательны",
+		"error.user_exists":          "Пользователь с таким логином уже существует",
+		"error.invalid_credentials":  "Неверный логин или пароль",
+		"error.no_session":           "Сессия не найдена",
+		"error.session_expired":      "Сессия истекла",
+
+		"app.tagline":               "Организуйте работу и жизнь",
+		"auth.welcome_back":         "С возвращением",
+		"auth.missed_you":           "Мы скучали!",
+		"auth.username":             "Логин",
+		"auth.password":             "Пароль",
+		"auth.sign_in":              "Войти",
+		"auth.sign_up":              "Зарегистрироваться",
+		"auth.no_account":           "Нет аккаунта?",
+		"auth.have_account":         "Уже есть аккаунт?",
+		"auth.create_account":       "Создать аккаунт",
+		"auth.join_us":              "Присоединяйтесь",
+		"auth.fill_all_fields":      "Заполните все поля",
+		"auth.login_failed":         "Не удалось войти",
+		"auth.register_failed":      "Не удалось зарегистрироваться",
+		"auth.account_created":      "Аккаунт создан! Теперь войдите.",
+		"common.hi":                 "Привет",
+		"common.network_error":      "Ошибка сети",
+		"common.cancel":             "Отмена",
+		"common.close":              "Закрыть",
+		"common.edit":               "✏️ Изменить",
+		"common.language":           "Язык",
+		"sidebar.welcome":           "Рады видеть вас снова",
+		"sidebar.logout":            "🚪 Выйти",
+		"sidebar.delete_account":    "🗑️ Удалить аккаунт",
+		"nav.tasks":                 "Задачи",
+		"nav.notes":                 "Заметки",
+		"tasks.title":               "Задачи на сегодня",
+		"tasks.new":                 "+ Новая задача",
+		"tasks.todo":                "К выполнению",
+		"tasks.completed":           "Выполнено",
+		"tasks.create_title":        "Новая задача",
+		"tasks.title_placeholder":   "Название задачи...",
+		"tasks.create":              "Создать задачу",
+		"tasks.error_create":        "Не удалось создать задачу",
+		"tasks.error_update":        "Не удалось обновить задачу",
+		"tasks.error_delete":        "Не удалось удалить задачу",
+		"tasks.rename_prompt":       "Новое название задачи",
+		"tasks.filter_all":          "Все",
+		"tasks.filter_today":        "Сегодня",
+		"tasks.filter_overdue":      "Просроченные",
+		"tasks.filter_week":         "На неделе",
+		"tasks.due_label":           "Срок (время необязательно)",
+		"tasks.remind_label":        "Напоминание",
+		"tasks.due":                 "Срок:",
+		"tasks.overdue":             "Просрочено:",
+		"tasks.reminder":            "Напоминание",
+		"tasks.repeat_label":        "Повторять",
+		"tasks.priority_label":      "Приоритет",
+		"tasks.add_subtask":         "Добавить подзадачу",
+		"tasks.subtask_prompt":      "Название подзадачи",
+		"tasks.priority_none":       "Без приоритета",
+		"tasks.priority_low":        "Низкий",
+		"tasks.priority_medium":     "Средний",
+		"tasks.priority_high":       "Высокий",
+		"tasks.priority_urgent":     "Срочно",
+		"tasks.sort_manual":         "Вручную",
+		"tasks.sort_priority":       "По приоритету",
+		"tasks.sort_due":            "По сроку",
+		"tasks.sort_created":        "По дате создания",
+		"tasks.repeat_none":         "Никогда",
+		"tasks.repeat_daily":        "Каждый день",
+		"tasks.repeat_weekly":       "Каждую неделю",
+		"tasks.repeat_monthly":      "Каждый месяц",
+		"tasks.repeat_after":        "Через день после выполнения",
+		"lists.all":                 "Все списки",
+		"lists.new":                 "+ Список",
+		"lists.label":               "Список",
+		"lists.name_prompt":         "Название списка",
+		"lists.error_create":        "Не удалось создать список",
+		"tags.all":                  "Все теги",
+		"tags.new":                  "+ Тег",
+		"tags.label":                "Теги",
+		"tags.name_prompt":          "Название тега",
+		"tags.error_create":         "Не удалось создать тег",
+		"notes.title":               "Мои заметки",
+		"notes.new":                 "+ Новая заметка",
+		"notes.create_title":        "Новая заметка",
+		"notes.title_placeholder":   "Название заметки...",
+		"notes.content_placeholder": "Текст заметки...",
+		"notes.create":              "Создать заметку",
+		"notes.edit_title":          "Редактирование заметки",
+		"notes.save":                "Сохранить",
+		"notes.created":             "Создана:",
+		"notes.updated":             "Обновлена:",
+		"notes.empty":               "Пока пусто...",
+		"notes.title_required":      "Нужно указать название заметки",
+		"notes.confirm_delete":      "Удалить эту заметку?",
+		"notes.error_create":        "Не удалось создать заметку",
+		"notes.error_update":        "Не удалось обновить заметку",
+		"notes.error_delete":        "Не удалось удалить заметку",
+		"notes.history":             "🕘 История",
+		"notes.compare":             "Сравнить",
+		"notes.restore":             "Восстановить",
+		"notes.confirm_restore":     "Восстановить эту версию? Текущая останется в истории.",
+		"search.placeholder":        "Поиск по заметкам и задачам...",
+		"nav.trash":                 "Корзина",
+		"trash.title":               "Корзина",
+		"trash.empty":               "Очистить корзину",
+		"trash.deleted":             "Удалено:",
+		"trash.restore":             "Восстановить",
+		"trash.delete_forever":      "Удалить навсегда",
+		"trash.confirm_delete":      "Удалить навсегда? Это не отменить.",
+		"trash.confirm_empty":       "Очистить корзину? Всё удалится навсегда.",
+		"trash.error":               "Не удалось выполнить действие",
+		"archive.show":              "📦 Архив",
+		"archive.move":              "В архив",
+		"archive.restore":           "Вернуть из архива",
+		"notes.pin":                 "Закрепить",
+		"notes.unpin":               "Открепить",
+		"notes.favorite":            "В избранное",
+		"notes.unfavorite":          "Убрать из избранного",
+		"notes.color":               "Цвет заметки",
+		"notes.words":               "слов",
+		"links.backlinks":           "Ссылаются сюда:",
+		"folders.all":               "Все заметки",
+		"folders.root":              "Без папки",
+		"folders.new":               "+ Папка",
+		"folders.rename":            "✏️ Переименовать",
+		"folders.delete":            "🗑️ Удалить папку",
+		"folders.name_prompt":       "Название папки:",
+		"folders.confirm_delete":    "Удалить открытую папку?",
+		"folders.confirm_cascade":   "Отправить заметки из неё в корзину? Отмена — перенести их и подпапки на уровень выше.",
+		"folders.error":             "Не удалось изменить папку",
+		"templates.none":            "Без шаблона",
+		"templates.save":            "📋 В шаблон",
+		"templates.delete":          "🗑️ Удалить шаблон",
+		"templates.name_prompt":     "Название шаблона:",
+		"templates.confirm_delete":  "Удалить выбранный шаблон?",
+		"templates.saved":           "Шаблон сохранён",
+		"templates.error":           "Не удалось изменить шаблон",
+		"weekday.sunday":            "воскресенье",
+		"weekday.monday":            "понедельник",
+		"weekday.tuesday":           "вторник",
+		"weekday.wednesday":         "среда",
+		"weekday.thursday":          "четверг",
+		"weekday.friday":            "пятница",
+		"weekday.saturday":          "суббота",
+		"links.todos":               "Задачи:",
+		"links.create_todos":        "☑ Задачи из чекбоксов",
+		"links.no_new_todos":        "Новых невыполненных пунктов нет",
+		"links.rewrite_confirm":     "Другие заметки ссылаются на старый заголовок. Перевести ссылки на новый?",
+		"notes.minutes":             "мин",
+		"attachments.add":           "📎 Прикрепить",
+		"attachments.error":         "Не удалось обработать вложение",
+		"attachments.confirm":       "Удалить вложение?",
+		"search.nothing_found":      "Ничего не найдено",
+		"search.kind_note":          "Заметка",
+		"search.kind_todo":          "Задача",
+		"account.delete_title":      "Удаление аккаунта",
+		"account.delete_text":       "Вы уверены, что хотите удалить аккаунт? Это действие нельзя отменить.",
+		"account.delete":            "Удалить аккаунт",
+		"account.confirm_delete":    "Вы уверены? Это действие нельзя отменить!",
+		"account.error_delete":      "Не удалось удалить аккаунт",
+	},
+	EN: {
+		"error.internal":             "Internal server error",
+		"error.unauthorized":         "Sign in required",
+		"error.token_required":       "Token required",
+		"error.route_not_found":      "Endpoint not found",
+		"error.method_not_allowed":   "HTTP method not allowed here",
+		"error.invalid_json":         "Invalid JSON",
+		"error.json_required":        "Request body must be JSON",
+		"error.invalid_id":           "Invalid ID",
+		"error.invalid_language":     "Unsupported language",
+		"error.todo_title_required":  "Task title must not be empty",
+		"error.note_title_required":  "Title is required",
+		"error.todo_not_found":       "Task not found",
+		"error.subtask_not_found":    "Subtask not found",
+		"error.list_not_found":       "List not found",
+		"error.list_archived":        "The list is archived and does not accept tasks",
+		"error.tag_not_found":        "Tag not found",
+		"error.invalid_cursor":       "Invalid page cursor",
+		"error.query_required":       "Search query is required",
+		"error.revision_not_found":   "Note revision not found",
+		"error.checkbox_not_found":   "The note has no such checkbox item",
+		"error.not_in_trash":         "Not found in trash",
+		"error.no_attachment":        "Attachment not found",
+		"error.not_linked":           "The task and the note are not linked",
+		"error.folder_not_found":     "Folder not found",
+		"error.folder_cycle":         "A folder cannot be moved into itself",
+		"error.template_not_found":   "Template not found",
+		"error.already_linked":       "The task and the note are already linked",
+		"error.quota_exceeded":       "Attachment quota exceeded",
+		"error.multipart_required":   "The file must be sent as multipart/form-data",
+		"error.file_required":        "A file is required in the file field",
+		"error.if_match_required":    "If-Match header with the ETag version is required",
+		"error.precondition_failed":  "This item was changed elsewhere — reload it and try again",
+		"error.tag_exists":           "A tag with this name already exists",
+		"error.inbox_protected":      "The Inbox list cannot be deleted or archived",
+		"error.invalid_color":        "Color must be in #rrggbb format",
+		"error.name_required":        "Name is required",
+		"error.subtask_too_deep":     "Subtasks are nested too deeply",
+		"error.invalid_due":          "Invalid due date: expected YYYY-MM-DD or a date-time",
+		"error.invalid_reminder":     "Invalid reminder time",
+		"error.invalid_timezone":     "Unknown time zone",
+		"error.invalid_filter":       "Unknown filter value",
+		"error.invalid_recurrence":   "Invalid recurrence rule",
+		"error.invalid_priority":     "Unknown priority",
+		"error.invalid_order":        "Task order has changed, reload the list",
+		"error.note_not_found":       "Note not found",
+		"error.user_not_found":       "User not found",
+		"error.credentials_required": "Login and password are required",
+		"error.user_exists":          "A user with this login already exists",
+		"error.invalid_credentials":  "Invalid login or password",
+		"error.no_session":           "Session not found",
+		"error.session_expired":      "Session expired",
+
+		"app.tagline":               "Organize your work and life",
+		"auth.welcome_back":         "Welcome Back",
+		"auth.missed_you":           "We missed you!",
+		"auth.username":             "Username",
+		"auth.password":             "Password",
+		"auth.sign_in":              "Sign In",
+		"auth.sign_up":              "Sign Up",
+		"auth.no_account":           "Don't have an account?",
+		"auth.have_account":         "Already have an account?",
+		"auth.create_account":       "Create Account",
+		"auth.join_us":              "Join us today",
+		"auth.fill_all_fields":      "Please fill in all fields",
+		"auth.login_failed":         "Login failed",
+		"auth.register_failed":      "Registration failed",
+		"auth.account_created":      "Account created! Please sign in.",
+		"common.hi":                 "Hi",
+		"common.network_error":      "Network error",
+		"common.cancel":             "Cancel",
+		"common.close":              "Close",
+		"common.edit":               "✏️ Edit",
+		"common.language":           "Language",
+		"sidebar.welcome":           "Welcome back to the workspace",
+		"sidebar.logout":            "🚪 Logout",
+		"sidebar.delete_account":    "🗑️ Delete Account",
+		"nav.tasks":                 "Tasks",
+		"nav.notes":                 "Notes",
+		"tasks.title":               "Today's Tasks",
+		"tasks.new":                 "+ New Task",
+		"tasks.todo":                "To Do",
+		"tasks.completed":           "Completed",
+		"tasks.create_title":        "Create New Task",
+		"tasks.title_placeholder":   "Task title...",
+		"tasks.create":              "Create Task",
+		"tasks.error_create":        "Error creating task",
+		"tasks.error_update":        "Error updating task",
+		"tasks.error_delete":        "Error deleting task",
+		"tasks.rename_prompt":       "New task title",
+		"tasks.filter_all":          "All",
+		"tasks.filter_today":        "Today",
+		"tasks.filter_overdue":      "Overdue",
+		"tasks.filter_week":         "This week",
+		"tasks.due_label":           "Due date (time is optional)",
+		"tasks.remind_label":        "Reminder",
+		"tasks.due":                 "Due:",
+		"tasks.overdue":             "Overdue:",
+		"tasks.reminder":            "Reminder",
+		"tasks.repeat_label":        "Repeat",
+		"tasks.priority_label":      "Priority",
+		"tasks.add_subtask":         "Add subtask",
+		"tasks.subtask_prompt":      "Subtask title",
+		"tasks.priority_none":       "No priority",
+		"tasks.priority_low":        "Low",
+		"tasks.priority_medium":     "Medium",
+		"tasks.priority_high":       "High",
+		"tasks.priority_urgent":     "Urgent",
+		"tasks.sort_manual":         "Manual order",
+		"tasks.sort_priority":       "By priority",
+		"tasks.sort_due":            "By due date",
+		"tasks.sort_created":        "By creation date",
+		"tasks.repeat_none":         "Never",
+		"tasks.repeat_daily":        "Every day",
+		"tasks.repeat_weekly":       "Every week",
+		"tasks.repeat_monthly":      "Every month",
+		"tasks.repeat_after":        "A day after completion",
+		"lists.all":                 "All lists",
+		"lists.new":                 "+ List",
+		"lists.label":               "List",
+		"lists.name_prompt":         "List name",
+		"lists.error_create":        "Error creating list",
+		"tags.all":                  "All tags",
+		"tags.new":                  "+ Tag",
+		"tags.label":                "Tags",
+		"tags.name_prompt":          "Tag name",
+		"tags.error_create":         "Error creating tag",
+		"notes.title":               "My Notes",
+		"notes.new":                 "+ New Note",
+		"notes.create_title":        "Create New Note",
+		"notes.title_placeholder":   "Note title...",
+		"notes.content_placeholder": "Note content...",
+		"notes.create":              "Create Note",
+		"notes.edit_title":          "Edit Note",
+		"notes.save":                "Save Changes",
+		"notes.created":             "Created:",
+		"notes.updated":             "Updated:",
+		"notes.empty":               "No content yet...",
+		"notes.title_required":      "Note title is required",
+		"notes.confirm_delete":      "Are you sure you want to delete this note?",
+		"notes.error_create":        "Error creating note",
+		"notes.error_update":        "Error updating note",
+		"notes.error_delete":        "Error deleting note",
+		"notes.history":             "🕘 History",
+		"notes.compare":             "Compare",
+		"notes.restore":             "Restore",
+		"notes.confirm_restore":     "Restore this revision? The current one stays in history.",
+		"search.placeholder":        "Search notes and tasks...",
+		"nav.trash":                 "Trash",
+		"trash.title":               "Trash",
+		"trash.empty":               "Empty trash",
+		"trash.deleted":             "Deleted:",
+		"trash.restore":             "Restore",
+		"trash.delete_forever":      "Delete forever",
+		"trash.confirm_delete":      "Delete forever? This cannot be undone.",
+		"trash.confirm_empty":       "Empty the trash? Everything will be deleted forever.",
+		"trash.error":               "Action failed",
+		"archive.show":              "📦 Archive",
+		"archive.move":              "Archive",
+		"archive.restore":           "Unarchive",
+		"notes.pin":                 "Pin",
+		"notes.unpin":               "Unpin",
+		"notes.favorite":            "Add to favorites",
+		"notes.unfavorite":          "Remove from favorites",
+		"notes.color":               "Note color",
+		"notes.words":               "words",
+		"links.backlinks":           "Linked from:",
+		"folders.all":               "All notes",
+		"folders.root":              "No folder",
+		"folders.new":               "+ Folder",
+		"folders.rename":            "✏️ Rename",
+		"folders.delete":            "🗑️ Delete folder",
+		"folders.name_prompt":       "Folder name:",
+		"folders.confirm_delete":    "Delete the open folder?",
+		"folders.confirm_cascade":   "Move its notes to the trash? Cancel moves them and subfolders one level up.",
+		"folders.error":             "Could not change the folder",
+		"templates.none":            "No template",
+		"templates.save":            "📋 Save as template",
+		"templates.delete":          "🗑️ Delete template",
+		"templates.name_prompt":     "Template name:",
+		"templates.confirm_delete":  "Delete the selected template?",
+		"templates.saved":           "Template saved",
+		"templates.error":           "Could not change the template",
+		"weekday.sunday":            "Sunday",
+		"weekday.monday":            "Monday",
+		"weekday.tuesday":           "Tuesday",
+		"weekday.wednesday":         "Wednesday",
+		"weekday.thursday":          "Thursday",
+		"weekday.friday":            "Friday",
+		"weekday.saturday":          "Saturday",
+		"links.todos":               "Tasks:",
+		"links.create_todos":        "☑ Tasks from checkboxes",
+		"links.no_new_todos":        "No new unchecked items",
+		"links.rewrite_confirm":     "Other notes link to the old title. Update the links to the new one?",
+		"notes.minutes":             "min",
+		"attachments.add":           "📎 Attach",
+		"attachments.error":         "Attachment action failed",
+		"attachments.confirm":       "Delete this attachment?",
+		"search.nothing_found":      "Nothing found",
+		"search.kind_note":          "Note",
+		"search.kind_todo":          "Task",
+		"account.delete_title":      "Delete Account",
+		"account.delete_text":       "Are you sure you want to delete your account? This action cannot be undone.",
+		"account.delete":            "Delete Account",
+		"account.confirm_delete":    "Are you sure? This action cannot be undone!",
+		"account.error_delete":      "Error deleting account",
+	},
+}
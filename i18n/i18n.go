@@ -0,0 +1,87 @@
+// Каталог сообщений для API и веб-интерфейса
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	RU = "ru"
+	EN = "en"
+
+	Default = RU
+)
+
+func Supported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// Языки, для которых есть каталог
+func Languages() []string {
+	langs := make([]string, 0, len(catalog))
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Перевод по ключу. Если в языке ключа нет — берём язык по умолчанию, потом сам ключ
+func T(lang, key string, args ...any) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Копия каталога языка — отдаётся фронтенду целиком
+func Catalog(lang string) map[string]string {
+	if !Supported(lang) {
+		lang = Default
+	}
+	messages := make(map[string]string, len(catalog[Default]))
+	for key, msg := range catalog[Default] {
+		messages[key] = msg
+	}
+	for key, msg := range catalog[lang] {
+		messages[key] = msg
+	}
+	return messages
+}
+
+// Выбирает язык по заголовку Accept-Language, например "en-US,en;q=0.9,ru;q=0.8"
+func Negotiate(acceptLanguage string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if Supported(primary) && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+
+	if best == "" {
+		return Default
+	}
+	return best
+}
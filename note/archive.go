@@ -0,0 +1,39 @@
+package note
+
+import "time"
+
+// Архивные заметки не попадают в обычный список, но открываются и редактируются как обычно
+func (notes Notes) Archived(archived bool) Notes {
+	result := Notes{}
+	for _, n := range notes {
+		if (n.ArchivedAt != nil) == archived {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (notes Notes) Archive(id int) (*Note, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.ArchivedAt == nil {
+		now := time.Now()
+		n.ArchivedAt = &now
+		n.Version++
+	}
+	return n, nil
+}
+
+func (notes Notes) Unarchive(id int) (*Note, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.ArchivedAt != nil {
+		n.ArchivedAt = nil
+		n.Version++
+	}
+	return n, nil
+}
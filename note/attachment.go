@@ -0,0 +1,71 @@
+package note
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("вложение не найдено")
+	ErrQuotaExceeded      = errors.New("превышена квота на вложения")
+)
+
+// Сколько байт вложений может хранить пользователь. Считаются уникальные файлы:
+// одинаковое вложение в двух заметках занимает место один раз.
+var AttachmentQuota int64 = 100 << 20
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"` // имя файла в blobs
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ID вложения уникален в пределах заметки
+func (notes Notes) Attach(id int, a Attachment) (*Attachment, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	a.ID = 1
+	for _, old := range n.Attachments {
+		a.ID = max(a.ID, old.ID+1)
+	}
+	a.CreatedAt = time.Now()
+
+	n.Attachments = append(n.Attachments, a)
+	n.Version++
+	return &n.Attachments[len(n.Attachments)-1], nil
+}
+
+func (notes Notes) Attachment(id, aid int) (*Attachment, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range n.Attachments {
+		if n.Attachments[i].ID == aid {
+			return &n.Attachments[i], nil
+		}
+	}
+	return nil, ErrAttachmentNotFound
+}
+
+// Файл остаётся в blobs, пока его не уберёт CollectBlobs
+func (notes Notes) Detach(id, aid int) error {
+	n, err := notes.Find(id)
+	if err != nil {
+		return err
+	}
+	for i, a := range n.Attachments {
+		if a.ID == aid {
+			n.Attachments = append(n.Attachments[:i], n.Attachments[i+1:]...)
+			n.Version++
+			return nil
+		}
+	}
+	return ErrAttachmentNotFound
+}
@@ -0,0 +1,155 @@
+package note
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sptodo/metrics"
+	"strings"
+)
+
+const tmpBlobPrefix = "tmp-"
+
+var blobName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func blobDir(login string) string {
+	return filepath.Join(dataDir, login, "blobs")
+}
+
+// Сколько места занимают файлы пользователя
+func BlobUsage(login string) (int64, error) {
+	entries, err := os.ReadDir(blobDir(login))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		if !blobName.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return 0, err
+		}
+		total += info.Size()
+	}
+	return total, nil
+}
+
+// Файл, который уже докачан во временный, но ещё не стал блобом
+type Upload struct {
+	login string
+	tmp   string
+	Hash  string
+	Size  int64
+}
+
+// Пишет поток во временный файл рядом с блобами и считает sha256. Файл идёт по сети
+// и может качаться долго, поэтому это делается без замка пользователя, а блобом
+// он становится только в Commit. Временные файлы CollectBlobs не трогает.
+func UploadBlob(login string, src io.Reader) (*Upload, error) {
+	defer metrics.StorageTimer("blobs", "write")()
+
+	dir := blobDir(login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(dir, tmpBlobPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	u := &Upload{login: login, tmp: tmp.Name()}
+
+	hasher := sha256.New()
+	u.Size, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		u.Discard()
+		return nil, err
+	}
+	u.Hash = hex.EncodeToString(hasher.Sum(nil))
+	return u, nil
+}
+
+// Кладёт файл под именем sha256 его содержимого. Если такой файл уже есть,
+// второй раз не пишем и квоту не тратим. Вызывающий держит замок пользователя
+// до записи заметок — иначе CollectBlobs удалит блоб, на который ещё никто не ссылается.
+func (u *Upload) Commit() error {
+	path := filepath.Join(blobDir(u.login), u.Hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	used, err := BlobUsage(u.login)
+	if err != nil {
+		return err
+	}
+	if used+u.Size > AttachmentQuota {
+		return ErrQuotaExceeded
+	}
+	if err := os.Rename(u.tmp, path); err != nil {
+		return err
+	}
+	u.tmp = ""
+	return nil
+}
+
+// Удаляет временный файл, если до Commit дело не дошло. Можно звать через defer всегда.
+func (u *Upload) Discard() {
+	if u.tmp != "" {
+		os.Remove(u.tmp)
+		u.tmp = ""
+	}
+}
+
+func OpenBlob(login, hash string) (*os.File, error) {
+	if !blobName.MatchString(hash) {
+		return nil, ErrAttachmentNotFound
+	}
+	file, err := os.Open(filepath.Join(blobDir(login), hash))
+	if os.IsNotExist(err) {
+		return nil, ErrAttachmentNotFound
+	}
+	return file, err
+}
+
+// Удаляет файлы, на которые не ссылается ни одна заметка. Заметки из корзины
+// тоже считаются — их ещё можно восстановить.
+func CollectBlobs(login string, notes Notes) error {
+	entries, err := os.ReadDir(blobDir(login))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, n := range notes {
+		for _, a := range n.Attachments {
+			used[a.SHA256] = true
+		}
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		// Временные файлы может прямо сейчас писать UploadBlob
+		if strings.HasPrefix(name, tmpBlobPrefix) || used[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(blobDir(login), name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package note
+
+import (
+	"sort"
+	"sptodo/tag"
+)
+
+func (notes Notes) SetPinned(id int, pinned bool) (*Note, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Pinned != pinned {
+		n.Pinned = pinned
+		n.Version++
+	}
+	return n, nil
+}
+
+func (notes Notes) SetFavorite(id int, favorite bool) (*Note, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Favorite != favorite {
+		n.Favorite = favorite
+		n.Version++
+	}
+	return n, nil
+}
+
+// Пустая строка убирает цвет
+func (notes Notes) SetColor(id int, color string) (*Note, error) {
+	if !tag.ValidColor(color) {
+		return nil, tag.ErrInvalidColor
+	}
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.Color != color {
+		n.Color = color
+		n.Version++
+	}
+	return n, nil
+}
+
+// Закреплённые заметки первыми, остальной порядок сохраняется
+func (notes Notes) PinnedFirst() Notes {
+	result := append(Notes{}, notes...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Pinned && !result[j].Pinned
+	})
+	return result
+}
@@ -0,0 +1,275 @@
+package note
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sptodo/metrics"
+	"sptodo/seq"
+	"strings"
+	"time"
+)
+
+// Корень дерева: заметки и папки без родителя
+const RootFolderID = 0
+
+// Что делать с содержимым удаляемой папки
+const (
+	DeleteReparent = "reparent" // папки и заметки поднимаются к родителю
+	DeleteCascade  = "cascade"  // всё поддерево удаляется, заметки уходят в корзину
+)
+
+var (
+	ErrFolderNotFound    = errors.New("папка не найдена")
+	ErrFolderCycle       = errors.New("папку нельзя перенести внутрь неё самой")
+	ErrInvalidDeleteMode = errors.New("неизвестный режим удаления папки")
+)
+
+type Folder struct {
+	ID        int       `json:"id"`
+	ParentID  int       `json:"parent_id"` // 0 — в корне
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type FolderPatch struct {
+	Name     *string `json:"name"`
+	ParentID *int    `json:"parent_id"`
+}
+
+// Узел дерева для боковой панели; Notes — заметки прямо в этой папке, без вложенных
+type FolderNode struct {
+	Folder
+	Notes    int          `json:"notes"`
+	Children []FolderNode `json:"children"`
+}
+
+type Folders []Folder
+
+// ID удалённой папки не достаётся новой: у заметок в корзине остался FolderID,
+// и после восстановления они оказались бы в чужой папке
+func (folders Folders) nextID() int {
+	maxID := 0
+	for _, f := range folders {
+		if f.ID > maxID {
+			maxID = f.ID
+		}
+	}
+	return seq.Next("folders", maxID)
+}
+
+func (folders Folders) Find(id int) (*Folder, error) {
+	for i := range folders {
+		if folders[i].ID == id {
+			return &folders[i], nil
+		}
+	}
+	return nil, ErrFolderNotFound
+}
+
+// Корень тоже годится как место для заметки или папки
+func (folders Folders) Check(id int) error {
+	if id == RootFolderID {
+		return nil
+	}
+	_, err := folders.Find(id)
+	return err
+}
+
+func (folders *Folders) Add(name string, parentID int) (Folder, error) {
+	if err := folders.Check(parentID); err != nil {
+		return Folder{}, err
+	}
+
+	folder := Folder{
+		ID:        folders.nextID(),
+		ParentID:  parentID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	*folders = append(*folders, folder)
+	return folder, nil
+}
+
+// Переименование и перенос; папку нельзя перенести в её же поддерево
+func (folders Folders) Update(id int, patch FolderPatch) (*Folder, error) {
+	folder, err := folders.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if patch.ParentID != nil {
+		if err := folders.Check(*patch.ParentID); err != nil {
+			return nil, err
+		}
+		if *patch.ParentID == id || folders.isDescendant(*patch.ParentID, id) {
+			return nil, ErrFolderCycle
+		}
+	}
+
+	if patch.Name != nil {
+		folder.Name = *patch.Name
+	}
+	if patch.ParentID != nil {
+		folder.ParentID = *patch.ParentID
+	}
+	return folder, nil
+}
+
+// Лежит ли папка id где-то внутри ancestor
+func (folders Folders) isDescendant(id, ancestor int) bool {
+	for id != RootFolderID {
+		folder, err := folders.Find(id)
+		if err != nil {
+			return false
+		}
+		if folder.ParentID == ancestor {
+			return true
+		}
+		id = folder.ParentID
+	}
+	return false
+}
+
+// Сама папка и все вложенные в неё
+func (folders Folders) Subtree(id int) []int {
+	ids := []int{id}
+	for _, f := range folders {
+		if f.ID != id && folders.isDescendant(f.ID, id) {
+			ids = append(ids, f.ID)
+		}
+	}
+	return ids
+}
+
+// Удаляет папку. Заметки переносит или отправляет в корзину сам метод, сохранить их — забота
+// вызывающего. Отдаёт ID заметок, ушедших в корзину.
+func (folders *Folders) Delete(id int, mode string, notes Notes) ([]int, error) {
+	folder, err := folders.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	parentID := folder.ParentID
+
+	var gone, trashed []int
+	switch mode {
+	case DeleteReparent:
+		gone = []int{id}
+		for i := range *folders {
+			if (*folders)[i].ParentID == id {
+				(*folders)[i].ParentID = parentID
+			}
+		}
+		for _, n := range notes {
+			if n.FolderID == id {
+				notes.MoveToFolder(n.ID, parentID)
+			}
+		}
+	case DeleteCascade:
+		gone = folders.Subtree(id)
+		for _, n := range notes {
+			if n.DeletedAt == nil && slices.Contains(gone, n.FolderID) {
+				notes.Delete(n.ID)
+				trashed = append(trashed, n.ID)
+			}
+		}
+	default:
+		return nil, ErrInvalidDeleteMode
+	}
+
+	kept := (*folders)[:0]
+	for _, f := range *folders {
+		if !slices.Contains(gone, f.ID) {
+			kept = append(kept, f)
+		}
+	}
+	*folders = kept
+	return trashed, nil
+}
+
+// Дерево папок с числом заметок; соседи по алфавиту
+func (folders Folders) Tree(notes Notes) []FolderNode {
+	counts := make(map[int]int)
+	for _, n := range notes {
+		counts[n.FolderID]++
+	}
+
+	var build func(parentID int) []FolderNode
+	build = func(parentID int) []FolderNode {
+		nodes := []FolderNode{}
+		for _, f := range folders {
+			if f.ParentID == parentID {
+				nodes = append(nodes, FolderNode{Folder: f, Notes: counts[f.ID], Children: build(f.ID)})
+			}
+		}
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return strings.ToLower(nodes[i].Name) < strings.ToLower(nodes[j].Name)
+		})
+		return nodes
+	}
+	return build(RootFolderID)
+}
+
+// Существование папки проверяет вызывающий
+func (notes Notes) MoveToFolder(id, folderID int) (*Note, error) {
+	n, err := notes.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if n.FolderID != folderID {
+		n.FolderID = folderID
+		n.Version++
+	}
+	return n, nil
+}
+
+// Заметки, лежащие прямо в папке
+func (notes Notes) InFolder(folderID int) Notes {
+	result := Notes{}
+	for _, n := range notes {
+		if n.FolderID == folderID {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func (folders Folders) Save(login string) error {
+	defer metrics.StorageTimer("folders", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "folders.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(folders); err != nil {
+		return err
+	}
+	return seq.Save()
+}
+
+func (folders *Folders) Load(login string) error {
+	defer metrics.StorageTimer("folders", "read")()
+
+	path := filepath.Join(dataDir, login, "folders.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			*folders = Folders{}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(folders)
+}
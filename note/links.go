@@ -0,0 +1,145 @@
+package note
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// [[Заголовок]] или [[#id]] — ссылка на другую заметку
+var wikiLinkRe = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+
+// Ссылка из текста заметки. NoteID — куда она ведёт сейчас, 0 — битая ссылка.
+type Link struct {
+	Target string `json:"target"`
+	NoteID int    `json:"note_id,omitempty"`
+}
+
+type BrokenLink struct {
+	NoteID int    `json:"note_id"`
+	Title  string `json:"title"`
+	Target string `json:"target"`
+}
+
+// Вызывает fn для строк вне блоков кода: ни чекбоксы, ни ссылки там не ищем
+func eachTextLine(src string, fn func(i int, line string)) {
+	var fence fenceScanner
+	for i, line := range splitLines(src) {
+		if kind, _ := fence.next(line); kind == textLine {
+			fn(i, line)
+		}
+	}
+}
+
+// Цели ссылок в тексте по порядку, без повторов
+func ParseLinks(src string) []string {
+	var targets []string
+	seen := make(map[string]bool)
+	eachTextLine(src, func(_ int, line string) {
+		for _, m := range wikiLinkRe.FindAllStringSubmatch(line, -1) {
+			target := strings.TrimSpace(m[1])
+			key := strings.ToLower(target)
+			if target != "" && !seen[key] {
+				seen[key] = true
+				targets = append(targets, target)
+			}
+		}
+	})
+	return targets
+}
+
+// Куда ведёт ссылка: #id — на заметку с этим ID, иначе на заметку с таким
+// заголовком без учёта регистра (при совпадении — на самую старую). Заметки из
+// корзины не считаются.
+func (notes Notes) Resolve(target string) int {
+	if rest, ok := strings.CutPrefix(target, "#"); ok {
+		if id, err := strconv.Atoi(rest); err == nil {
+			if _, err := notes.Find(id); err == nil {
+				return id
+			}
+			return 0
+		}
+	}
+	for _, n := range notes {
+		if n.DeletedAt == nil && strings.EqualFold(strings.TrimSpace(n.Title), target) {
+			return n.ID
+		}
+	}
+	return 0
+}
+
+// Пересчитывает граф ссылок целиком: заголовки меняются, заметки удаляются,
+// и битая ссылка может ожить без правки самой заметки
+func (notes Notes) Relink() {
+	for i := range notes {
+		notes[i].Links = nil
+		for _, target := range ParseLinks(notes[i].Content) {
+			notes[i].Links = append(notes[i].Links, Link{Target: target, NoteID: notes.Resolve(target)})
+		}
+	}
+}
+
+// Заметки, которые ссылаются на id
+func (notes Notes) Backlinks(id int) Notes {
+	result := Notes{}
+	for _, n := range notes.Alive() {
+		for _, l := range n.Links {
+			if l.NoteID == id {
+				result = append(result, n)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func (notes Notes) BrokenLinks() []BrokenLink {
+	result := []BrokenLink{}
+	for _, n := range notes.Alive() {
+		for _, l := range n.Links {
+			if l.NoteID == 0 {
+				result = append(result, BrokenLink{NoteID: n.ID, Title: n.Title, Target: l.Target})
+			}
+		}
+	}
+	return result
+}
+
+// Можно ли перевести ссылку [[target]] из n на заметку id: она битая или уже ведёт в id.
+// Если под этим заголовком нашлась другая заметка, ссылка про неё — её не трогаем.
+func (n Note) CanRewriteLink(target string, id int) bool {
+	for _, l := range n.Links {
+		if strings.EqualFold(l.Target, target) {
+			return l.NoteID == 0 || l.NoteID == id
+		}
+	}
+	return false
+}
+
+// Заменяет ссылки [[from]] на [[to]] вне блоков кода; отдаёт новый текст и число замен
+func RewriteLinks(src, from, to string) (string, int) {
+	lines := splitLines(src)
+	count := 0
+	eachTextLine(src, func(i int, line string) {
+		lines[i] = wikiLinkRe.ReplaceAllStringFunc(line, func(link string) string {
+			if !strings.EqualFold(strings.TrimSpace(link[2:len(link)-2]), from) {
+				return link
+			}
+			count++
+			return "[[" + to + "]]"
+		})
+	})
+	if count == 0 {
+		return src, 0
+	}
+	return strings.Join(lines, "\n"), count
+}
+
+// Заголовок, которым можно сослаться на заметку; если он ломает синтаксис ссылки — #id
+func (n Note) LinkTarget() string {
+	title := strings.TrimSpace(n.Title)
+	if title == "" || strings.ContainsAny(title, "[]\n") || strings.HasPrefix(title, "#") {
+		return "#" + strconv.Itoa(n.ID)
+	}
+	return title
+}
@@ -0,0 +1,355 @@
+package note
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrCheckboxNotFound = errors.New("в заметке нет такого пункта-чекбокса")
+
+// Подмножество CommonMark: заголовки, абзацы, списки (в том числе с чекбоксами),
+// цитаты, блоки кода, горизонтальные линии; в строке — код, жирный, курсив,
+// зачёркнутый и ссылки. Сырой HTML из текста никогда не проходит — всё экранируется,
+// а ссылки пропускаются только с безопасными схемами.
+
+var (
+	headingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	hrRe      = regexp.MustCompile(`^ {0,3}(?:(?:-[ \t]*){3,}|(?:\*[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	quoteRe   = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	ulRe      = regexp.MustCompile(`^ {0,3}[-*+][ \t]+(.*)$`)
+	olRe      = regexp.MustCompile(`^ {0,3}(\d{1,9})[.)][ \t]+(.*)$`)
+	fenceRe   = regexp.MustCompile("^ {0,3}(```+|~~~+)[ \t]*([^`\\s]*)")
+
+	// Пункт списка с чекбоксом: "- [ ] текст", "1. [x] текст"
+	checkboxRe = regexp.MustCompile(`^( {0,3}(?:[-*+]|\d{1,9}[.)])[ \t]+\[)([ xX])(\](?:[ \t]+(.*))?)$`)
+
+	codeSpanRe = regexp.MustCompile("`([^`]+)`")
+	linkRe     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	boldRe     = regexp.MustCompile(`\*\*(.+?)\*\*|__(.+?)__`)
+	strikeRe   = regexp.MustCompile(`~~(.+?)~~`)
+	italicRe   = regexp.MustCompile(`\*([^*]+)\*|\b_([^_]+)_\b`)
+	holderRe   = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// Пункт-чекбокс в тексте заметки. Index — порядковый номер, по нему чекбоксы
+// адресуются в API и в data-checkbox отрендеренного HTML.
+type Checkbox struct {
+	Index   int    `json:"index"`
+	Line    int    `json:"line"` // номер строки в Content, с нуля
+	Checked bool   `json:"checked"`
+	Text    string `json:"text"`
+}
+
+func splitLines(src string) []string {
+	return strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+}
+
+// Все чекбоксы вне блоков кода, по порядку. Рендерер нумерует их так же.
+func Checkboxes(src string) []Checkbox {
+	var boxes []Checkbox
+	eachTextLine(src, func(i int, line string) {
+		if m := checkboxRe.FindStringSubmatch(line); m != nil {
+			boxes = append(boxes, Checkbox{Index: len(boxes), Line: i, Checked: m[2] != " ", Text: m[4]})
+		}
+	})
+	return boxes
+}
+
+// Пункт, запомненный по номеру и тексту на момент, когда на него сослались
+type CheckboxRef struct {
+	Index int
+	Text  string
+}
+
+// Находит запомненные пункты в тексте по тексту: номера сдвигаются, когда в заметку
+// добавляют пункты. Каждый пункт достаётся одной ссылке, а одинаковые пункты
+// ("- [ ] купить" дважды) разбираются по порядку запомненных номеров — вставка строки
+// выше их не перепутает. Отдаёт пункт для каждой ссылки по порядку, nil — пункта больше нет.
+func MatchCheckboxes(src string, refs []CheckboxRef) []*Checkbox {
+	boxes := Checkboxes(src)
+	claimed := make([]bool, len(boxes))
+	result := make([]*Checkbox, len(refs))
+
+	order := make([]int, len(refs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return refs[order[a]].Index < refs[order[b]].Index })
+
+	for _, i := range order {
+		for j := range boxes {
+			if !claimed[j] && boxes[j].Text == refs[i].Text {
+				claimed[j] = true
+				result[i] = &boxes[j]
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Ставит или снимает чекбокс с номером index прямо в исходном тексте
+func SetCheckbox(src string, index int, checked bool) (string, error) {
+	boxes := Checkboxes(src)
+	if index < 0 || index >= len(boxes) {
+		return "", ErrCheckboxNotFound
+	}
+
+	mark := " "
+	if checked {
+		mark = "x"
+	}
+	lines := splitLines(src)
+	n := boxes[index].Line
+	lines[n] = checkboxRe.ReplaceAllString(lines[n], "${1}"+mark+"${3}")
+	return strings.Join(lines, "\n"), nil
+}
+
+// Блоки кода ``` и ~~~. Блок закрывает строка из одного забора того же символа
+// не короче открывающего, как в CommonMark. Рендерер и поиск чекбоксов и ссылок
+// (eachTextLine) смотрят на блоки одинаково, иначе номера чекбоксов разъедутся с data-checkbox.
+type fenceScanner struct {
+	fence string // открывающий забор, "" — вне блока
+}
+
+type fenceLine int
+
+const (
+	textLine fenceLine = iota
+	fenceOpen
+	codeLine
+	fenceClose
+)
+
+// Разбирает очередную строку; lang — язык из открывающего забора
+func (f *fenceScanner) next(line string) (kind fenceLine, lang string) {
+	m := fenceRe.FindStringSubmatch(line)
+	if f.fence == "" {
+		if m == nil {
+			return textLine, ""
+		}
+		f.fence = m[1]
+		return fenceOpen, m[2]
+	}
+	if m != nil && m[1][0] == f.fence[0] && len(m[1]) >= len(f.fence) && strings.TrimSpace(line) == m[1] {
+		f.fence = ""
+		return fenceClose, ""
+	}
+	return codeLine, ""
+}
+
+func (f *fenceScanner) inside() bool {
+	return f.fence != ""
+}
+
+type renderer struct {
+	b     strings.Builder
+	para  []string
+	list  string   // "ul", "ol" или "" — открытый список
+	item  []string // строки текущего пункта
+	box   int      // номер следующего чекбокса, -1 — чекбоксы не нужны (внутри цитаты)
+	quote []string
+}
+
+func RenderHTML(src string) string {
+	r := &renderer{}
+	r.render(splitLines(strings.ReplaceAll(src, "\x00", "")))
+	return r.b.String()
+}
+
+func (r *renderer) render(lines []string) {
+	var fence fenceScanner
+	for _, line := range lines {
+		if fence.inside() {
+			if kind, _ := fence.next(line); kind == fenceClose {
+				r.b.WriteString("</code></pre>\n")
+				continue
+			}
+			r.b.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		if m := quoteRe.FindStringSubmatch(line); m != nil {
+			r.flushBlocks(false)
+			r.quote = append(r.quote, m[1])
+			continue
+		}
+		r.flushQuote()
+
+		if kind, lang := fence.next(line); kind == fenceOpen {
+			r.flushBlocks(true)
+			if lang != "" {
+				fmt.Fprintf(&r.b, `<pre><code class="language-%s">`, html.EscapeString(lang))
+			} else {
+				r.b.WriteString("<pre><code>")
+			}
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			r.flushBlocks(true)
+		case hrRe.MatchString(line):
+			r.flushBlocks(true)
+			r.b.WriteString("<hr>\n")
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			r.flushBlocks(true)
+			fmt.Fprintf(&r.b, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+		case ulRe.MatchString(line):
+			r.listItem("ul", "", line)
+		case olRe.MatchString(line):
+			r.listItem("ol", olRe.FindStringSubmatch(line)[1], line)
+		case r.list != "":
+			// Продолжение пункта списка
+			r.item = append(r.item, strings.TrimSpace(line))
+		default:
+			r.para = append(r.para, strings.TrimSpace(line))
+		}
+	}
+
+	r.flushQuote()
+	r.flushBlocks(true)
+	if fence.inside() {
+		r.b.WriteString("</code></pre>\n")
+	}
+}
+
+func (r *renderer) listItem(kind, start, line string) {
+	r.flushPara()
+	r.flushItem()
+	if r.list != kind {
+		r.closeList()
+		r.list = kind
+		if kind == "ol" && start != "" && start != "1" {
+			n, _ := strconv.Atoi(start)
+			fmt.Fprintf(&r.b, "<ol start=\"%d\">\n", n)
+		} else {
+			fmt.Fprintf(&r.b, "<%s>\n", kind)
+		}
+	}
+	r.item = []string{line}
+}
+
+func (r *renderer) flushItem() {
+	if r.item == nil {
+		return
+	}
+	first := r.item[0]
+	rest := r.item[1:]
+	r.item = nil
+
+	if m := checkboxRe.FindStringSubmatch(first); m != nil && r.box >= 0 {
+		checked := ""
+		if m[2] != " " {
+			checked = " checked"
+		}
+		text := strings.Join(append([]string{m[4]}, rest...), "\n")
+		fmt.Fprintf(&r.b, "<li class=\"task-list-item\"><input type=\"checkbox\" data-checkbox=\"%d\"%s> %s</li>\n", r.box, checked, inline(text))
+		r.box++
+		return
+	}
+
+	var text string
+	if m := ulRe.FindStringSubmatch(first); m != nil {
+		text = m[1]
+	} else {
+		text = olRe.FindStringSubmatch(first)[2]
+	}
+	fmt.Fprintf(&r.b, "<li>%s</li>\n", inline(strings.Join(append([]string{text}, rest...), "\n")))
+}
+
+func (r *renderer) closeList() {
+	r.flushItem()
+	if r.list != "" {
+		fmt.Fprintf(&r.b, "</%s>\n", r.list)
+		r.list = ""
+	}
+}
+
+func (r *renderer) flushPara() {
+	if len(r.para) > 0 {
+		fmt.Fprintf(&r.b, "<p>%s</p>\n", inline(strings.Join(r.para, "\n")))
+		r.para = nil
+	}
+}
+
+// closeList=false — цитата внутри пункта не закрывает список
+func (r *renderer) flushBlocks(closeList bool) {
+	r.flushPara()
+	if closeList {
+		r.closeList()
+	} else {
+		r.flushItem()
+	}
+}
+
+// Цитата рендерится отдельно; чекбоксы внутри неё — просто текст
+func (r *renderer) flushQuote() {
+	if r.quote == nil {
+		return
+	}
+	inner := &renderer{box: -1}
+	inner.render(r.quote)
+	r.quote = nil
+	r.b.WriteString("<blockquote>\n" + inner.b.String() + "</blockquote>\n")
+}
+
+// Строчная разметка. Код и ссылки сначала прячутся за заглушками \x00N\x00,
+// чтобы звёздочки и подчёркивания внутри них не превратились в курсив.
+func inline(s string) string {
+	var held []string
+	hold := func(v string) string {
+		held = append(held, v)
+		return "\x00" + strconv.Itoa(len(held)-1) + "\x00"
+	}
+
+	s = codeSpanRe.ReplaceAllStringFunc(s, func(m string) string {
+		return hold("<code>" + html.EscapeString(codeSpanRe.FindStringSubmatch(m)[1]) + "</code>")
+	})
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		parts := linkRe.FindStringSubmatch(m)
+		text := emphasis(html.EscapeString(parts[1]))
+		if !safeURL(parts[2]) {
+			return hold(text)
+		}
+		return hold(`<a href="` + html.EscapeString(parts[2]) + `" rel="nofollow noopener" target="_blank">` + text + "</a>")
+	})
+
+	s = emphasis(html.EscapeString(s))
+	s = strings.ReplaceAll(s, "\n", "<br>\n")
+
+	// Заглушка может прятать другие: код внутри текста ссылки
+	var expand func(s string) string
+	expand = func(s string) string {
+		return holderRe.ReplaceAllStringFunc(s, func(m string) string {
+			i, _ := strconv.Atoi(holderRe.FindStringSubmatch(m)[1])
+			return expand(held[i])
+		})
+	}
+	return expand(s)
+}
+
+func emphasis(s string) string {
+	s = boldRe.ReplaceAllString(s, "<strong>$1$2</strong>")
+	s = strikeRe.ReplaceAllString(s, "<del>$1</del>")
+	return italicRe.ReplaceAllString(s, "<em>$1$2</em>")
+}
+
+// Пропускаем http(s), mailto и относительные ссылки; javascript:, data: и прочее — нет
+func safeURL(u string) bool {
+	i := strings.IndexAny(u, ":/?#")
+	if i < 0 || u[i] != ':' {
+		return true
+	}
+	switch strings.ToLower(u[:i]) {
+	case "http", "https", "mailto":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package note
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Номера чекбоксов из Checkboxes должны совпадать с data-checkbox в HTML
+func TestCheckboxIndexMatchesRender(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"забор с языком не закрывает блок", "````\n- [ ] a\n```go\n- [ ] b\n````\n- [ ] c", []string{"c"}},
+		{"короткий забор не закрывает блок", "````\n- [ ] a\n```\n- [ ] b\n````\n- [ ] c", []string{"c"}},
+		{"тильды не закрывают бэктики", "```\n~~~\n- [ ] a\n```\n- [ ] b", []string{"b"}},
+		{"незакрытый блок до конца", "- [x] a\n```\n- [ ] b", []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := Checkboxes(tt.src)
+			if len(boxes) != len(tt.want) {
+				t.Fatalf("чекбоксов %d, want %d", len(boxes), len(tt.want))
+			}
+			html := RenderHTML(tt.src)
+			for i, box := range boxes {
+				if box.Text != tt.want[i] {
+					t.Errorf("#%d: текст %q, want %q", i, box.Text, tt.want[i])
+				}
+				attr := `data-checkbox="` + strconv.Itoa(box.Index) + `"`
+				if !strings.Contains(html, attr+">"+" "+box.Text) && !strings.Contains(html, attr+" checked> "+box.Text) {
+					t.Errorf("в HTML нет %s для %q:\n%s", attr, box.Text, html)
+				}
+			}
+			if n := strings.Count(html, "data-checkbox="); n != len(boxes) {
+				t.Errorf("в HTML %d чекбоксов, want %d", n, len(boxes))
+			}
+		})
+	}
+}
+
+func TestInlineCodeInsideLink(t *testing.T) {
+	got := RenderHTML("[`go test` docs](https://go.dev)")
+	if strings.Contains(got, "\x00") {
+		t.Fatalf("в HTML осталась заглушка: %q", got)
+	}
+	if !strings.Contains(got, "<code>go test</code> docs</a>") {
+		t.Errorf("got %q", got)
+	}
+}
+
+// Одинаковые пункты достаются разным ссылкам в порядке их прежних номеров
+func TestMatchCheckboxesClaimsEachBoxOnce(t *testing.T) {
+	src := "- [ ] новый\n- [ ] купить\n- [x] купить\n- [ ] позвонить"
+	refs := []CheckboxRef{
+		{Index: 1, Text: "купить"},    // был вторым "купить" — им и остался
+		{Index: 0, Text: "купить"},    // сверху вставили строку
+		{Index: 5, Text: "купить"},    // свободных "купить" больше нет
+		{Index: 2, Text: "позвонить"}, // сдвинулся на один
+	}
+
+	got := MatchCheckboxes(src, refs)
+	want := []int{2, 1, -1, 3}
+	for i, box := range got {
+		index := -1
+		if box != nil {
+			index = box.Index
+		}
+		if index != want[i] {
+			t.Errorf("ссылка %d: пункт %d, want %d", i, index, want[i])
+		}
+	}
+}
@@ -5,21 +5,43 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"sptodo/metrics"
+	"sptodo/seq"
+	"sptodo/tag"
+	"strings"
 	"time"
 )
 
 const dataDir = "data"
 
+var ErrNotFound = errors.New("заметка не найдена")
+
 type Note struct {
 	ID        int       `json:"id"`
+	Version   int       `json:"version"` // растёт при каждой правке, отдаётся как ETag
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"update_at"`
+	Tags      []int     `json:"tags,omitempty"` // ID из tag.Tags
+	FolderID  int       `json:"folder_id"`      // 0 — в корне, туда попадают и старые заметки
+	// Оформление в сетке заметок, меняется отдельными запросами
+	Pinned   bool   `json:"pinned,omitempty"`
+	Favorite bool   `json:"favorite,omitempty"`
+	Color    string `json:"color,omitempty"`
+	// Ссылки [[...]] из текста, пересчитываются при сохранении — см. Relink
+	Links []Link `json:"links,omitempty"`
+	// Сами файлы лежат в data/<login>/blobs, см. UploadBlob
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Заметка в корзине: не видна в списках, пока её не восстановят или не удалят совсем
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
 
 type Notes []Note
 
+// ID не переиспользуются и после удаления из корзины — см. пакет seq
 func (notes Notes) nextID() int {
 	maxID := 0
 	for _, n := range notes {
@@ -27,46 +49,101 @@ func (notes Notes) nextID() int {
 			maxID = n.ID
 		}
 	}
-	return maxID + 1
+	return seq.Next("notes", maxID)
 }
 
-func (notes *Notes) Add(title, content string) {
+func (notes *Notes) Add(title, content string) Note {
 	now := time.Now()
 	newNote := Note{
 		ID:        notes.nextID(),
 		Title:     title,
 		Content:   content,
+		Version:   1,
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 
 	*notes = append(*notes, newNote)
+	return newNote
 }
 
 func (notes *Notes) Update(id int, title, content string) error {
-	for i := range *notes {
-		if (*notes)[i].ID == id {
-			(*notes)[i].Title = title
-			(*notes)[i].Content = content
-			(*notes)[i].UpdatedAt = time.Now()
-			return nil
+	n, err := notes.Find(id)
+	if err != nil {
+		return err
+	}
+
+	n.Title = title
+	n.Content = content
+	n.UpdatedAt = time.Now()
+	n.Version++
+	return nil
+}
+
+// Заметки из корзины не находятся — для них есть trashIndex
+func (notes Notes) Find(id int) (*Note, error) {
+	for i := range notes {
+		if notes[i].ID == id && notes[i].DeletedAt == nil {
+			return &notes[i], nil
 		}
 	}
-	return errors.New("Not note")
+	return nil, ErrNotFound
 }
 
-func (notes *Notes) Delete(id int) error {
-	for i, n := range *notes {
-		if n.ID == id {
-			*notes = append((*notes)[:i], (*notes)[i+1:]...)
-			return nil
+// Существование тегов проверяет вызывающий
+func (notes Notes) SetTags(id int, tags []int) error {
+	n, err := notes.Find(id)
+	if err != nil {
+		return err
+	}
+	n.Tags = tag.Normalize(tags)
+	return nil
+}
+
+// Заметки с тегами want; mode — tag.ModeAny или tag.ModeAll
+func (notes Notes) WithTags(want []int, mode string) Notes {
+	result := Notes{}
+	for _, n := range notes {
+		if tag.Match(n.Tags, want, mode) {
+			result = append(result, n)
 		}
 	}
+	return result
+}
 
-	return errors.New("Not note")
+// Снимает удалённый тег со всех заметок
+func (notes Notes) RemoveTag(id int) {
+	for i := range notes {
+		if !slices.Contains(notes[i].Tags, id) {
+			continue
+		}
+		notes[i].Tags = slices.DeleteFunc(notes[i].Tags, func(t int) bool { return t == id })
+		if len(notes[i].Tags) == 0 {
+			notes[i].Tags = nil
+		}
+		notes[i].Version++
+	}
 }
 
+// Переносит заметку в корзину. Насовсем удаляет Purge.
+func (notes *Notes) Delete(id int) error {
+	n, err := notes.Find(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	n.DeletedAt = &now
+	n.Version++
+	return nil
+}
+
+// Перед записью пересчитывает граф ссылок, так он всегда совпадает с текстом и заголовками
 func (notes Notes) Save(login string) error {
+	defer metrics.StorageTimer("notes", "write")()
+
+	notes.Relink()
+
 	dir := filepath.Join(dataDir, login)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return err
@@ -81,10 +158,15 @@ func (notes Notes) Save(login string) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	return encoder.Encode(notes)
+	if err := encoder.Encode(notes); err != nil {
+		return err
+	}
+	return seq.Save()
 }
 
 func (notes *Notes) Load(login string) error {
+	defer metrics.StorageTimer("notes", "read")()
+
 	path := filepath.Join(dataDir, login, "notes.json")
 	file, err := os.Open(path)
 	if err != nil {
@@ -96,5 +178,23 @@ func (notes *Notes) Load(login string) error {
 	}
 	defer file.Close()
 
-	return json.NewDecoder(file).Decode(notes)
+	if err := json.NewDecoder(file).Decode(notes); err != nil {
+		return err
+	}
+
+	// Заметки, сохранённые до появления версий
+	for i := range *notes {
+		if (*notes)[i].Version == 0 {
+			(*notes)[i].Version = 1
+		}
+	}
+
+	// Заметки, сохранённые до появления ссылок: граф ещё не посчитан
+	for _, n := range *notes {
+		if n.Links == nil && strings.Contains(n.Content, "[[") {
+			notes.Relink()
+			break
+		}
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package note
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Длина выдержки в символах: по умолчанию и предел для ?excerpt=
+const (
+	DefaultExcerpt = 200
+	MaxExcerpt     = 1000
+)
+
+// Скорость чтения для оценки времени, слов в минуту
+const wordsPerMinute = 200
+
+var tagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Карточка заметки для списка: вместо содержимого — выдержка простым текстом
+type Preview struct {
+	ID          int        `json:"id"`
+	Version     int        `json:"version"`
+	Title       string     `json:"title"`
+	Excerpt     string     `json:"excerpt"`
+	Words       int        `json:"words"`
+	ReadingTime int        `json:"reading_minutes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Tags        []int      `json:"tags,omitempty"`
+	FolderID    int        `json:"folder_id"`
+	Pinned      bool       `json:"pinned"`
+	Favorite    bool       `json:"favorite"`
+	Color       string     `json:"color,omitempty"`
+	Attachments int        `json:"attachments"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+}
+
+func (n Note) Preview(length int) Preview {
+	text := PlainText(n.Content)
+	words := countWords(text)
+
+	return Preview{
+		ID:          n.ID,
+		Version:     n.Version,
+		Title:       n.Title,
+		Excerpt:     excerpt(text, length),
+		Words:       words,
+		ReadingTime: (words + wordsPerMinute - 1) / wordsPerMinute,
+		CreatedAt:   n.CreatedAt,
+		UpdatedAt:   n.UpdatedAt,
+		Tags:        n.Tags,
+		FolderID:    n.FolderID,
+		Pinned:      n.Pinned,
+		Favorite:    n.Favorite,
+		Color:       n.Color,
+		Attachments: len(n.Attachments),
+		ArchivedAt:  n.ArchivedAt,
+	}
+}
+
+func (notes Notes) Previews(length int) []Preview {
+	result := make([]Preview, len(notes))
+	for i, n := range notes {
+		result[i] = n.Preview(length)
+	}
+	return result
+}
+
+// Текст заметки без разметки. Разметку разбирает RenderHTML, так что выдержка
+// совпадает с тем, что видно в заметке.
+func PlainText(src string) string {
+	text := tagPattern.ReplaceAllString(RenderHTML(src), " ")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// Словом считаем всё, где есть буква или цифра, — тире и маркеры списков не в счёт
+func countWords(text string) int {
+	count := 0
+	for _, field := range strings.Fields(text) {
+		if strings.IndexFunc(field, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Обрезает по границе слова, чтобы не резать слово пополам
+func excerpt(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	cut := string(runes[:length])
+	if i := strings.LastIndexByte(cut, ' '); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
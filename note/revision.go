@@ -0,0 +1,135 @@
+package note
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sptodo/metrics"
+	"time"
+)
+
+var ErrRevisionNotFound = errors.New("версия заметки не найдена")
+
+// Сколько последних версий хранить на заметку. Меняется через SPTODO_NOTE_REVISIONS.
+var MaxRevisions = 50
+
+// Снимок заметки после очередного сохранения
+type Revision struct {
+	NoteID    int       `json:"note_id"`
+	Rev       int       `json:"rev"` // номер версии внутри заметки, растёт и после удаления старых
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Все версии всех заметок пользователя, по порядку записи
+type Revisions []Revision
+
+// Версии одной заметки, новые первыми
+func (revs Revisions) Of(noteID int) Revisions {
+	result := Revisions{}
+	for i := len(revs) - 1; i >= 0; i-- {
+		if revs[i].NoteID == noteID {
+			result = append(result, revs[i])
+		}
+	}
+	return result
+}
+
+func (revs Revisions) Find(noteID, rev int) (*Revision, error) {
+	for i := range revs {
+		if revs[i].NoteID == noteID && revs[i].Rev == rev {
+			return &revs[i], nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// Запоминает текущее состояние заметки и выкидывает версии сверх MaxRevisions
+func (revs *Revisions) Record(n Note) Revision {
+	last := 0
+	for _, r := range *revs {
+		if r.NoteID == n.ID {
+			last = max(last, r.Rev)
+		}
+	}
+
+	rev := Revision{
+		NoteID:    n.ID,
+		Rev:       last + 1,
+		Title:     n.Title,
+		Content:   n.Content,
+		CreatedAt: n.UpdatedAt,
+	}
+	*revs = append(*revs, rev)
+
+	// Старые версии идут раньше, поэтому удаляем с начала
+	extra := len(revs.Of(n.ID)) - MaxRevisions
+	kept := (*revs)[:0]
+	for _, r := range *revs {
+		if r.NoteID == n.ID && extra > 0 {
+			extra--
+			continue
+		}
+		kept = append(kept, r)
+	}
+	*revs = kept
+
+	return rev
+}
+
+// Вызывается перед изменением заметки. Заметки, созданные до появления версий,
+// так получают первую версию — прежнее состояние не теряется. true — версию добавили.
+func (revs *Revisions) Before(n Note) bool {
+	if len(revs.Of(n.ID)) == 0 {
+		revs.Record(n)
+		return true
+	}
+	return false
+}
+
+func (revs *Revisions) Forget(noteID int) {
+	kept := (*revs)[:0]
+	for _, r := range *revs {
+		if r.NoteID != noteID {
+			kept = append(kept, r)
+		}
+	}
+	*revs = kept
+}
+
+func (revs Revisions) Save(login string) error {
+	defer metrics.StorageTimer("revisions", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "revisions.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(revs)
+}
+
+func (revs *Revisions) Load(login string) error {
+	defer metrics.StorageTimer("revisions", "read")()
+
+	path := filepath.Join(dataDir, login, "revisions.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			*revs = Revisions{}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(revs)
+}
@@ -0,0 +1,160 @@
+package note
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sptodo/metrics"
+	"sptodo/seq"
+	"time"
+)
+
+var ErrTemplateNotFound = errors.New("шаблон не найден")
+
+// {{date}}, {{ time }} — имя подстановки из латинских букв, пробелы внутри скобок допустимы
+var placeholderRe = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// Шаблон заметки. В Title и Content могут быть подстановки, их раскрывает Expand при создании заметки.
+type Template struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TemplatePatch struct {
+	Name    *string `json:"name"`
+	Title   *string `json:"title"`
+	Content *string `json:"content"`
+}
+
+type Templates []Template
+
+// ID удалённого шаблона не достаётся новому: клиент с устаревшим template_id
+// молча получил бы чужой шаблон
+func (templates Templates) nextID() int {
+	maxID := 0
+	for _, t := range templates {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	return seq.Next("templates", maxID)
+}
+
+func (templates *Templates) Add(name, title, content string) Template {
+	now := time.Now()
+	t := Template{
+		ID:        templates.nextID(),
+		Name:      name,
+		Title:     title,
+		Content:   content,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	*templates = append(*templates, t)
+	return t
+}
+
+func (templates Templates) Find(id int) (*Template, error) {
+	for i := range templates {
+		if templates[i].ID == id {
+			return &templates[i], nil
+		}
+	}
+	return nil, ErrTemplateNotFound
+}
+
+func (templates Templates) Update(id int, patch TemplatePatch) (*Template, error) {
+	t, err := templates.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Name != nil {
+		t.Name = *patch.Name
+	}
+	if patch.Title != nil {
+		t.Title = *patch.Title
+	}
+	if patch.Content != nil {
+		t.Content = *patch.Content
+	}
+	t.UpdatedAt = time.Now()
+	return t, nil
+}
+
+func (templates *Templates) Delete(id int) error {
+	for i, t := range *templates {
+		if t.ID == id {
+			*templates = append((*templates)[:i], (*templates)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrTemplateNotFound
+}
+
+// Раскрывает подстановки из vars; незнакомые остаются в тексте как есть
+func Expand(text string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(text, func(m string) string {
+		name := placeholderRe.FindStringSubmatch(m)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return m
+	})
+}
+
+// Заголовок и текст новой заметки по шаблону: что задано явно, то и остаётся,
+// пустое берётся из шаблона с раскрытыми подстановками
+func (t Template) Apply(title, content string, vars map[string]string) (string, string) {
+	if title == "" {
+		title = Expand(t.Title, vars)
+	}
+	if content == "" {
+		content = Expand(t.Content, vars)
+	}
+	return title, content
+}
+
+func (templates Templates) Save(login string) error {
+	defer metrics.StorageTimer("templates", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "templates.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(templates); err != nil {
+		return err
+	}
+	return seq.Save()
+}
+
+func (templates *Templates) Load(login string) error {
+	defer metrics.StorageTimer("templates", "read")()
+
+	path := filepath.Join(dataDir, login, "templates.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			*templates = Templates{}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(templates)
+}
@@ -0,0 +1,52 @@
+package note
+
+import "testing"
+
+var templateVars = map[string]string{"date": "2026-10-19", "time": "09:30", "weekday": "понедельник"}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"известные подстановки", "{{date}} {{time}}, {{weekday}}", "2026-10-19 09:30, понедельник"},
+		{"пробелы внутри скобок", "Встреча {{ date }} в {{time }}", "Встреча 2026-10-19 в 09:30"},
+		{"незнакомая остаётся как есть", "{{date}} {{ author }}", "2026-10-19 {{ author }}"},
+		{"не подстановка", "{{Date}} {{}} {date}", "{{Date}} {{}} {date}"},
+		{"без подстановок", "просто текст", "просто текст"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Expand(tt.text, templateVars); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateApply(t *testing.T) {
+	tmpl := Template{Title: "Дневник {{date}}", Content: "## {{weekday}}\n{{ mood }}"}
+
+	tests := []struct {
+		name           string
+		title, content string
+		wantTitle      string
+		wantContent    string
+	}{
+		{"всё из шаблона", "", "", "Дневник 2026-10-19", "## понедельник\n{{ mood }}"},
+		{"явный заголовок", "Мой день", "", "Мой день", "## понедельник\n{{ mood }}"},
+		{"явный текст", "", "уже написано", "Дневник 2026-10-19", "уже написано"},
+		{"явное не раскрывается", "{{date}}", "{{time}}", "{{date}}", "{{time}}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, content := tmpl.Apply(tt.title, tt.content, templateVars)
+			if title != tt.wantTitle || content != tt.wantContent {
+				t.Errorf("got (%q, %q), want (%q, %q)", title, content, tt.wantTitle, tt.wantContent)
+			}
+		})
+	}
+}
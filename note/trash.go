@@ -0,0 +1,78 @@
+package note
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrNotInTrash = errors.New("в корзине нет такой заметки")
+
+// Заметки не из корзины — всё, что показывается в списках
+func (notes Notes) Alive() Notes {
+	result := Notes{}
+	for _, n := range notes {
+		if n.DeletedAt == nil {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// Содержимое корзины, недавно удалённые первыми
+func (notes Notes) Trash() Notes {
+	result := Notes{}
+	for _, n := range notes {
+		if n.DeletedAt != nil {
+			result = append(result, n)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DeletedAt.After(*result[j].DeletedAt)
+	})
+	return result
+}
+
+func (notes Notes) trashIndex(id int) int {
+	for i, n := range notes {
+		if n.ID == id && n.DeletedAt != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (notes Notes) Restore(id int) (*Note, error) {
+	i := notes.trashIndex(id)
+	if i < 0 {
+		return nil, ErrNotInTrash
+	}
+	notes[i].DeletedAt = nil
+	notes[i].Version++
+	return &notes[i], nil
+}
+
+// Удаляет заметку из корзины насовсем
+func (notes *Notes) Purge(id int) error {
+	i := notes.trashIndex(id)
+	if i < 0 {
+		return ErrNotInTrash
+	}
+	*notes = append((*notes)[:i], (*notes)[i+1:]...)
+	return nil
+}
+
+// Удаляет насовсем всё, что лежит в корзине дольше, чем до before; отдаёт ID удалённых
+func (notes *Notes) PurgeBefore(before time.Time) []int {
+	var purged []int
+	kept := (*notes)[:0]
+	for _, n := range *notes {
+		if n.DeletedAt != nil && n.DeletedAt.Before(before) {
+			purged = append(purged, n.ID)
+			continue
+		}
+		kept = append(kept, n)
+	}
+	*notes = kept
+	return purged
+}
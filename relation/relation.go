@@ -0,0 +1,155 @@
+package relation
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sptodo/metrics"
+	"time"
+)
+
+const dataDir = "data"
+
+var (
+	ErrNotFound = errors.New("задача и заметка не связаны")
+	ErrExists   = errors.New("задача и заметка уже связаны")
+)
+
+// Связь задачи с заметкой. Если задача создана из чекбокса заметки, Checkbox —
+// его номер (см. note.Checkboxes), а CheckboxText — текст, по которому пункт
+// находится снова, если номера сдвинулись после правки заметки.
+type Relation struct {
+	TodoID       int       `json:"todo_id"`
+	NoteID       int       `json:"note_id"`
+	Checkbox     *int      `json:"checkbox,omitempty"`
+	CheckboxText string    `json:"checkbox_text,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Relations []Relation
+
+func (rels Relations) index(todoID, noteID int) int {
+	for i, rel := range rels {
+		if rel.TodoID == todoID && rel.NoteID == noteID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (rels Relations) Find(todoID, noteID int) (*Relation, error) {
+	i := rels.index(todoID, noteID)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return &rels[i], nil
+}
+
+// Существование задачи и заметки проверяет вызывающий
+func (rels *Relations) Add(todoID, noteID int) (Relation, error) {
+	if rels.index(todoID, noteID) >= 0 {
+		return Relation{}, ErrExists
+	}
+	rel := Relation{TodoID: todoID, NoteID: noteID, CreatedAt: time.Now()}
+	*rels = append(*rels, rel)
+	return rel, nil
+}
+
+// Связь задачи, созданной из чекбокса заметки
+func (rels *Relations) AddCheckbox(todoID, noteID, checkbox int, text string) Relation {
+	rel := Relation{TodoID: todoID, NoteID: noteID, Checkbox: &checkbox, CheckboxText: text, CreatedAt: time.Now()}
+	*rels = append(*rels, rel)
+	return rel
+}
+
+func (rels *Relations) Remove(todoID, noteID int) error {
+	i := rels.index(todoID, noteID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	*rels = append((*rels)[:i], (*rels)[i+1:]...)
+	return nil
+}
+
+// ID заметок, связанных с задачей
+func (rels Relations) NotesOf(todoID int) []int {
+	var ids []int
+	for _, rel := range rels {
+		if rel.TodoID == todoID {
+			ids = append(ids, rel.NoteID)
+		}
+	}
+	return ids
+}
+
+// ID задач, связанных с заметкой
+func (rels Relations) TodosOf(noteID int) []int {
+	var ids []int
+	for _, rel := range rels {
+		if rel.NoteID == noteID {
+			ids = append(ids, rel.TodoID)
+		}
+	}
+	return ids
+}
+
+// Связи с пунктами-чекбоксами заметки
+func (rels Relations) Checkboxes(noteID int) []*Relation {
+	var result []*Relation
+	for i := range rels {
+		if rels[i].NoteID == noteID && rels[i].Checkbox != nil {
+			result = append(result, &rels[i])
+		}
+	}
+	return result
+}
+
+// Убирает связи удалённых насовсем задач и заметок; отдаёт, было ли что убирать
+func (rels *Relations) Forget(todoIDs, noteIDs []int) bool {
+	kept := (*rels)[:0]
+	for _, rel := range *rels {
+		if !slices.Contains(todoIDs, rel.TodoID) && !slices.Contains(noteIDs, rel.NoteID) {
+			kept = append(kept, rel)
+		}
+	}
+	changed := len(kept) != len(*rels)
+	*rels = kept
+	return changed
+}
+
+func (rels Relations) Save(login string) error {
+	defer metrics.StorageTimer("relations", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "relations.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(rels)
+}
+
+func (rels *Relations) Load(login string) error {
+	defer metrics.StorageTimer("relations", "read")()
+
+	path := filepath.Join(dataDir, login, "relations.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			*rels = Relations{}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(rels)
+}
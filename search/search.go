@@ -0,0 +1,341 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Что индексируется
+const (
+	KindNote = "note"
+	KindTodo = "todo"
+)
+
+// Совпадение в названии весит больше, чем в тексте
+const titleWeight = 3
+
+// Сколько символов текста показывать вокруг первого совпадения
+const snippetRadius = 60
+
+type Doc struct {
+	Kind  string
+	ID    int
+	Title string
+	Body  string
+}
+
+type Result struct {
+	Kind    string  `json:"kind"`
+	ID      int     `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"` // HTML: текст экранирован, совпадения в <mark>
+	Score   float64 `json:"score"`
+}
+
+type docKey struct {
+	kind string
+	id   int
+}
+
+// Сколько раз терм встретился в названии и в тексте документа
+type posting struct {
+	title, body int
+}
+
+// Индекс одного пользователя
+type userIndex struct {
+	mu    sync.Mutex
+	built bool
+	docs  map[docKey]Doc
+	terms map[string]map[docKey]posting
+}
+
+// Обратный индекс по всем пользователям. Индекс пользователя строится при первом
+// поиске через load, дальше обработчики поддерживают его через Put и Remove.
+type Index struct {
+	mu    sync.Mutex
+	users map[string]*userIndex
+	load  func(login string) ([]Doc, error)
+}
+
+func NewIndex(load func(login string) ([]Doc, error)) *Index {
+	return &Index{users: make(map[string]*userIndex), load: load}
+}
+
+func (ix *Index) user(login string) *userIndex {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	u, ok := ix.users[login]
+	if !ok {
+		u = &userIndex{}
+		ix.users[login] = u
+	}
+	return u
+}
+
+// Добавляет или заменяет документ. Пока индекс пользователя не построен, делать
+// ничего не нужно: он всё равно прочитается с диска целиком.
+func (ix *Index) Put(login string, doc Doc) {
+	u := ix.user(login)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.built {
+		u.remove(docKey{doc.Kind, doc.ID})
+		u.add(doc)
+	}
+}
+
+func (ix *Index) Remove(login, kind string, id int) {
+	u := ix.user(login)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.built {
+		u.remove(docKey{kind, id})
+	}
+}
+
+// Забывает пользователя целиком — после удаления аккаунта
+func (ix *Index) Drop(login string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	delete(ix.users, login)
+}
+
+func (u *userIndex) add(doc Doc) {
+	key := docKey{doc.Kind, doc.ID}
+	u.docs[key] = doc
+	for _, t := range tokenize(doc.Title) {
+		p := u.posting(t.term, key)
+		p.title++
+		u.terms[t.term][key] = p
+	}
+	for _, t := range tokenize(doc.Body) {
+		p := u.posting(t.term, key)
+		p.body++
+		u.terms[t.term][key] = p
+	}
+}
+
+func (u *userIndex) posting(term string, key docKey) posting {
+	if u.terms[term] == nil {
+		u.terms[term] = make(map[docKey]posting)
+	}
+	return u.terms[term][key]
+}
+
+func (u *userIndex) remove(key docKey) {
+	doc, ok := u.docs[key]
+	if !ok {
+		return
+	}
+	delete(u.docs, key)
+	for _, t := range append(tokenize(doc.Title), tokenize(doc.Body)...) {
+		delete(u.terms[t.term], key)
+		if len(u.terms[t.term]) == 0 {
+			delete(u.terms, t.term)
+		}
+	}
+}
+
+func (ix *Index) Search(login, query string, limit int) ([]Result, error) {
+	words := Tokenize(query)
+	if len(words) == 0 {
+		return []Result{}, nil
+	}
+
+	u := ix.user(login)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if !u.built {
+		docs, err := ix.load(login)
+		if err != nil {
+			return nil, err
+		}
+		u.docs = make(map[docKey]Doc)
+		u.terms = make(map[string]map[docKey]posting)
+		for _, doc := range docs {
+			u.add(doc)
+		}
+		u.built = true
+	}
+
+	// Каждое слово запроса — префикс: "зам" находит "заметка". Документ должен
+	// содержать все слова; точное совпадение слова весит больше префиксного.
+	var scores map[docKey]float64
+	for _, word := range words {
+		found := make(map[docKey]float64)
+		for term, postings := range u.terms {
+			if !strings.HasPrefix(term, word) {
+				continue
+			}
+			boost := 1.0
+			if term == word {
+				boost = 2
+			}
+			for key, p := range postings {
+				found[key] += boost * float64(titleWeight*p.title+p.body)
+			}
+		}
+
+		if scores == nil {
+			scores = found
+			continue
+		}
+		for key := range scores {
+			if s, ok := found[key]; ok {
+				scores[key] += s
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for key, score := range scores {
+		doc := u.docs[key]
+		results = append(results, Result{
+			Kind:    doc.Kind,
+			ID:      doc.ID,
+			Title:   doc.Title,
+			Snippet: snippet(doc, words),
+			Score:   score,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if results[i].Kind != results[j].Kind {
+			return results[i].Kind < results[j].Kind
+		}
+		return results[i].ID < results[j].ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+type token struct {
+	term       string
+	start, end int // байтовые смещения в исходной строке
+}
+
+// Слово — подряд идущие буквы и цифры любого алфавита. Регистр не важен, ё = е.
+func tokenize(s string) []token {
+	var tokens []token
+	var term strings.Builder
+	start := -1
+
+	flush := func(end int) {
+		if start >= 0 {
+			tokens = append(tokens, token{term.String(), start, end})
+			term.Reset()
+			start = -1
+		}
+	}
+
+	for i, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush(i)
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		r = unicode.ToLower(r)
+		if r == 'ё' {
+			r = 'е'
+		}
+		term.WriteRune(r)
+	}
+	flush(len(s))
+	return tokens
+}
+
+// Нормализованные слова строки — так же, как их видит индекс
+func Tokenize(s string) []string {
+	tokens := tokenize(s)
+	words := make([]string, len(tokens))
+	for i, t := range tokens {
+		words[i] = t.term
+	}
+	return words
+}
+
+func matches(term string, words []string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(term, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Кусок текста вокруг первого совпадения с подсветкой. Если в тексте совпадений
+// нет (нашлось только по названию), берём начало текста или само название.
+func snippet(doc Doc, words []string) string {
+	text := doc.Body
+	tokens := tokenize(text)
+
+	first := -1
+	for _, t := range tokens {
+		if matches(t.term, words) {
+			first = t.start
+			break
+		}
+	}
+	if first < 0 && text == "" {
+		text = doc.Title
+		tokens = tokenize(text)
+	}
+
+	from, to := 0, len(text)
+	if first >= 0 {
+		from = max(0, first-snippetRadius)
+		// Начинаем с целого слова
+		if i := strings.IndexFunc(text[from:first], unicode.IsSpace); from > 0 && i >= 0 {
+			from += i + 1
+		}
+	}
+	if to-from > 2*snippetRadius {
+		to = from + 2*snippetRadius
+	}
+	// Границы не должны резать символ посередине
+	for from > 0 && !utf8.RuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !utf8.RuneStart(text[to]) {
+		to++
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, t := range tokens {
+		if t.start < from || t.end > to || !matches(t.term, words) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[t.start:t.end]))
+		b.WriteString("</mark>")
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(text[pos:to]))
+	if to < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
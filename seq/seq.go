@@ -0,0 +1,81 @@
+// Счётчики ID, которые только растут. С max+1 ID удалённой насовсем задачи или заметки
+// доставался следующей новой, и старые ссылки ([[#id]], папки, поиск) молча вели в неё.
+// Счётчик один на вид данных и общий для всех пользователей: ID внутри пользователя
+// идут с пропусками, зато никогда не повторяются.
+package seq
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sptodo/metrics"
+	"sync"
+)
+
+const dataDir = "data"
+
+var (
+	mu    sync.Mutex
+	last  map[string]int // nil — файл ещё не читали
+	dirty bool
+)
+
+func path() string {
+	return filepath.Join(dataDir, "ids.json")
+}
+
+// Следующий ID вида kind. floor — наибольший ID в загруженных данных: если счётчик
+// отстал (файл потерян, данные старше счётчика), ID всё равно не совпадёт с живым.
+func Next(kind string, floor int) int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if last == nil {
+		last = load()
+	}
+	id := max(last[kind], floor) + 1
+	last[kind] = id
+	dirty = true
+	return id
+}
+
+// Без файла начинаем с нуля; битый файл тоже не повод падать — выручит floor в Next
+func load() map[string]int {
+	defer metrics.StorageTimer("ids", "read")()
+
+	counters := make(map[string]int)
+	data, err := os.ReadFile(path())
+	if err == nil {
+		json.Unmarshal(data, &counters)
+	}
+	return counters
+}
+
+// Записывает счётчики, если с прошлой записи выдавались новые ID. Вызывается из Save данных.
+func Save() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !dirty {
+		return nil
+	}
+	defer metrics.StorageTimer("ids", "write")()
+
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	// Через временный файл: оборванная запись не должна обнулить счётчики
+	tmp := path() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path()); err != nil {
+		return err
+	}
+	dirty = false
+	return nil
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sptodo/note"
+	"sptodo/todo"
+	"strconv"
+	"time"
+)
+
+// Через сколько дней выполненная задача уходит в архив, меняется через SPTODO_ARCHIVE_DAYS (0 — не архивировать)
+const defaultArchiveDays = 7
+
+const autoArchiveInterval = time.Hour
+
+var archiveBeat = &heartbeat{what: "архивация", interval: autoArchiveInterval}
+
+func startAutoArchive() error {
+	days := defaultArchiveDays
+	if v := os.Getenv("SPTODO_ARCHIVE_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("SPTODO_ARCHIVE_DAYS: ожидается неотрицательное число, получено %q", v)
+		}
+		days = n
+	}
+	if days == 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(autoArchiveInterval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			archiveBeat.beat()
+			before := time.Now().AddDate(0, 0, -days)
+			for _, login := range authSystem.Logins() {
+				if err := autoArchive(login, before); err != nil {
+					log.Printf("архивация %s: %v", login, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func autoArchive(login string, before time.Time) error {
+	// Задача работает в фоне, мимо requireAuth — замок берём сами
+	defer lockUser(login)()
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		return err
+	}
+	if len(todos.ArchiveCompletedBefore(before)) == 0 {
+		return nil
+	}
+	return todos.Save(login)
+}
+
+// ?archived=true — только архив, по умолчанию архив не отдаём
+func archivedFilter(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("archived")
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+func archiveTodo(w http.ResponseWriter, r *http.Request) {
+	setTodoArchived(w, r, true)
+}
+
+func unarchiveTodo(w http.ResponseWriter, r *http.Request) {
+	setTodoArchived(w, r, false)
+}
+
+func setTodoArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	archive := todos.Unarchive
+	if archived {
+		archive = todos.Archive
+	}
+	task, err := archive(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(task.Version))
+	writeJSON(w, http.StatusOK, task)
+}
+
+func archiveNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, note.Notes.Archive)
+}
+
+func unarchiveNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, note.Notes.Unarchive)
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sptodo/note"
+	"strconv"
+	"strings"
+)
+
+// Размер multipart-обёртки поверх самого файла
+const multipartOverhead = 1 << 20
+
+// Квота в мегабайтах, меняется через SPTODO_ATTACHMENT_QUOTA_MB
+func configureAttachments() error {
+	v := os.Getenv("SPTODO_ATTACHMENT_QUOTA_MB")
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return fmt.Errorf("SPTODO_ATTACHMENT_QUOTA_MB: ожидается положительное число, получено %q", v)
+	}
+	note.AttachmentQuota = n << 20
+	return nil
+}
+
+// Тип берём по расширению, а если оно ничего не говорит — по первым байтам
+func attachmentType(name string, head []byte) string {
+	if ctype := mime.TypeByExtension(filepath.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return http.DetectContentType(head)
+}
+
+// Прямо в браузере открываем только то, что не может выполнить скрипт
+func inlineType(ctype string) bool {
+	mediaType, _, _ := mime.ParseMediaType(ctype)
+	switch {
+	case mediaType == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mediaType, "image/"), mediaType == "application/pdf", mediaType == "text/plain":
+		return true
+	}
+	return false
+}
+
+func getAttachments(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	attachments := n.Attachments
+	if attachments == nil {
+		attachments = []note.Attachment{}
+	}
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// Файл приходит полем file в multipart/form-data и читается потоком, не целиком в память
+func addAttachment(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	// Для несуществующей заметки файл даже не читаем. Маршрут без замка
+	// (см. requireAuthStream), берём его только на чтение заметок.
+	unlock := lockUser(login)
+	var notes note.Notes
+	err := notes.Load(login)
+	if err == nil {
+		_, err = notes.Find(id)
+	}
+	unlock()
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	// Больше квоты всё равно не сохраним — не даём и читать
+	r.Body = http.MaxBytesReader(w, r.Body, note.AttachmentQuota+multipartOverhead)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "error.multipart_required")
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			if errors.As(err, new(*http.MaxBytesError)) {
+				writeDomainError(w, r, note.ErrQuotaExceeded)
+				return
+			}
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.file_required", map[string]string{"file": "required"})
+			return
+		}
+		if part.FormName() != "file" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		name := filepath.Base(part.FileName())
+		body := bufio.NewReaderSize(part, 512)
+		head, _ := body.Peek(512)
+		ctype := attachmentType(name, head)
+
+		upload, err := note.UploadBlob(login, body)
+		part.Close()
+		if err != nil {
+			if errors.As(err, new(*http.MaxBytesError)) {
+				err = note.ErrQuotaExceeded
+			}
+			writeDomainError(w, r, err)
+			return
+		}
+		defer upload.Discard()
+
+		created, err := attachUpload(login, id, upload, note.Attachment{Name: name, ContentType: ctype})
+		if err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("/api/notes/%d/attachments/%d", id, created.ID))
+		writeJSON(w, http.StatusCreated, created)
+		return
+	}
+}
+
+// Пока файл качался, заметки могли поменять другие запросы — перечитываем их
+// и привязываем файл под замком, чтобы CollectBlobs не удалил его раньше Save
+func attachUpload(login string, id int, upload *note.Upload, a note.Attachment) (*note.Attachment, error) {
+	defer lockUser(login)()
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		return nil, err
+	}
+	if _, err := notes.Find(id); err != nil {
+		return nil, err
+	}
+	if err := upload.Commit(); err != nil {
+		return nil, err
+	}
+
+	a.SHA256, a.Size = upload.Hash, upload.Size
+	created, err := notes.Attach(id, a)
+	if err != nil {
+		return nil, err
+	}
+	if err := notes.Save(login); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Диапазоны (Range), If-Range и If-None-Match обрабатывает http.ServeContent
+func downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	aid, ok := pathID(w, r, "aid")
+	if !ok {
+		return
+	}
+
+	a, file, err := openAttachment(login, id, aid)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	disposition := "attachment"
+	if inlineType(a.ContentType) {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	// Содержимое файла с этим хэшем не меняется никогда
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+
+	http.ServeContent(w, r, "", a.CreatedAt, file)
+}
+
+// Замок нужен только пока ищем вложение и открываем файл: открытый файл
+// отдаём уже без него, даже если CollectBlobs тем временем его удалит
+func openAttachment(login string, id, aid int) (*note.Attachment, *os.File, error) {
+	defer lockUser(login)()
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		return nil, nil, err
+	}
+	a, err := notes.Attachment(id, aid)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := note.OpenBlob(login, a.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, file, nil
+}
+
+func deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	aid, ok := pathID(w, r, "aid")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := notes.Detach(id, aid); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := note.CollectBlobs(login, notes); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sptodo/auth"
+	"sptodo/i18n"
+	"sptodo/note"
+	"sptodo/relation"
+	"sptodo/tag"
+	"sptodo/todo"
+)
+
+// Машиночитаемые коды ошибок API
+const (
+	CodeBadRequest   = "bad_request"
+	CodeInvalidJSON  = "invalid_json"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeNotFound     = "not_found"
+	CodeMethod       = "method_not_allowed"
+	CodeConflict     = "conflict"
+	CodeInternal     = "internal_error"
+
+	CodePreconditionFailed   = "precondition_failed"
+	CodePreconditionRequired = "precondition_required"
+	CodeQuotaExceeded        = "quota_exceeded"
+)
+
+// Единый формат ошибки: {"error": {...}}
+type APIError struct {
+	Code      string            `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// message — ключ каталога i18n, переводится на язык запроса
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeErrorDetails(w, r, status, code, message, nil)
+}
+
+func writeErrorDetails(w http.ResponseWriter, r *http.Request, status int, code, message string, details map[string]string) {
+	requestID, _ := r.Context().Value("request_id").(string)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: APIError{
+		Code:      code,
+		Message:   i18n.T(requestLang(r), message),
+		Details:   details,
+		RequestID: requestID,
+	}})
+}
+
+// Переводит ошибки пакетов todo, note и auth в HTTP-статус.
+// Неизвестные ошибки наружу не отдаём — только общий текст.
+func writeDomainError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, todo.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.todo_not_found")
+	case errors.Is(err, todo.ErrListNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.list_not_found")
+	case errors.Is(err, todo.ErrListArchived):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.list_archived", map[string]string{"list_id": "archived"})
+	case errors.Is(err, todo.ErrInboxProtected):
+		writeError(w, r, http.StatusConflict, CodeConflict, "error.inbox_protected")
+	case errors.Is(err, tag.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.tag_not_found")
+	case errors.Is(err, note.ErrFolderNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.folder_not_found")
+	case errors.Is(err, note.ErrTemplateNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.template_not_found")
+	case errors.Is(err, note.ErrFolderCycle):
+		writeError(w, r, http.StatusConflict, CodeConflict, "error.folder_cycle")
+	case errors.Is(err, note.ErrInvalidDeleteMode):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"mode": "invalid"})
+	case errors.Is(err, relation.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.not_linked")
+	case errors.Is(err, relation.ErrExists):
+		writeError(w, r, http.StatusConflict, CodeConflict, "error.already_linked")
+	case errors.Is(err, tag.ErrExists):
+		writeError(w, r, http.StatusConflict, CodeConflict, "error.tag_exists")
+	case errors.Is(err, note.ErrAttachmentNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.no_attachment")
+	case errors.Is(err, note.ErrQuotaExceeded):
+		writeError(w, r, http.StatusRequestEntityTooLarge, CodeQuotaExceeded, "error.quota_exceeded")
+	case errors.Is(err, tag.ErrInvalidColor):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_color", map[string]string{"color": "invalid"})
+	case errors.Is(err, tag.ErrInvalidMode):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"tag_mode": "invalid"})
+	case errors.Is(err, todo.ErrInvalidCursor):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_cursor", map[string]string{"cursor": "invalid"})
+	case errors.Is(err, todo.ErrSubtaskNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.subtask_not_found")
+	case errors.Is(err, todo.ErrSubtaskTooDeep):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.subtask_too_deep", map[string]string{"parent_id": "too_deep"})
+	case errors.Is(err, todo.ErrInvalidDue):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_due", map[string]string{"due": "invalid"})
+	case errors.Is(err, todo.ErrInvalidReminder):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_reminder", map[string]string{"remind_at": "invalid"})
+	case errors.Is(err, todo.ErrInvalidTimezone):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_timezone", map[string]string{"tz": "invalid"})
+	case errors.Is(err, todo.ErrInvalidRecurrence):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_recurrence", map[string]string{"recurrence": "invalid"})
+	case errors.Is(err, todo.ErrInvalidPriority):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_priority", map[string]string{"priority": "invalid"})
+	case errors.Is(err, todo.ErrInvalidOrder):
+		writeError(w, r, http.StatusConflict, CodeConflict, "error.invalid_order")
+	case errors.Is(err, todo.ErrInvalidFilter):
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter")
+	case errors.Is(err, todo.ErrNotInTrash), errors.Is(err, note.ErrNotInTrash):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.not_in_trash")
+	case errors.Is(err, note.ErrCheckboxNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.checkbox_not_found")
+	case errors.Is(err, note.ErrRevisionNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.revision_not_found")
+	case errors.Is(err, note.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.note_not_found")
+	case errors.Is(err, auth.ErrUserNotFound):
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.user_not_found")
+	case errors.Is(err, auth.ErrEmptyCredentials):
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.credentials_required", map[string]string{
+			"login":    "required",
+			"password": "required",
+		})
+	case errors.Is(err, auth.ErrUserExists):
+		writeErrorDetails(w, r, http.StatusConflict, CodeConflict, "error.user_exists", map[string]string{"login": "taken"})
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "error.invalid_credentials")
+	case errors.Is(err, auth.ErrNoSession):
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "error.no_session")
+	case errors.Is(err, auth.ErrSessionExpired):
+		writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "error.session_expired")
+	default:
+		// Клиенту подробности не отдаём, но по request_id из ответа их можно найти в логе
+		requestID, _ := r.Context().Value("request_id").(string)
+		log.Printf("[%s] %s %s: %v", requestID, r.Method, r.URL.Path, err)
+		writeError(w, r, http.StatusInternalServerError, CodeInternal, "error.internal")
+	}
+}
+
+// Берёт X-Request-ID от клиента или генерирует свой
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "request_id", id)))
+	})
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,34 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ETag задачи или заметки — её версия в кавычках: "3"
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Правка без If-Match — 428, с устаревшей версией — 412 и текущая версия в ответе.
+// Так две вкладки не перезаписывают друг друга молча. Возвращает false, если ответ уже записан.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		writeError(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, "error.if_match_required")
+		return false
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+
+	w.Header().Set("ETag", current)
+	writeErrorDetails(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "error.precondition_failed", map[string]string{"version": strconv.Itoa(version)})
+	return false
+}
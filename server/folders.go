@@ -0,0 +1,204 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/note"
+	"sptodo/search"
+	"strconv"
+)
+
+type AddFolderRequest struct {
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id"` // 0 — в корне
+}
+
+type MoveNoteRequest struct {
+	FolderID int `json:"folder_id"`
+}
+
+// Корень — не папка, поэтому число заметок в нём отдельно
+type FolderTreeResponse struct {
+	Notes   int               `json:"notes"`
+	Folders []note.FolderNode `json:"folders"`
+}
+
+func checkFolderExists(login string, id int) error {
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		return err
+	}
+	return folders.Check(id)
+}
+
+func getFolders(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, folders)
+}
+
+// Дерево для боковой панели. Считаются заметки, видные в обычном списке, — без корзины и архива.
+func getFolderTree(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	visible := notes.Alive().Archived(false)
+	writeJSON(w, http.StatusOK, FolderTreeResponse{
+		Notes:   len(visible.InFolder(note.RootFolderID)),
+		Folders: folders.Tree(visible),
+	})
+}
+
+func addFolder(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req AddFolderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if req.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	created, err := folders.Add(req.Name, req.ParentID)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := folders.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/folders/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Переименование и перенос папки
+func patchFolder(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var patch note.FolderPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	updated, err := folders.Update(id, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := folders.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// ?mode=reparent (по умолчанию) поднимает содержимое к родителю, ?mode=cascade
+// удаляет вложенные папки и отправляет их заметки в корзину
+func deleteFolder(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = note.DeleteReparent
+	}
+
+	var folders note.Folders
+	if err := folders.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	trashed, err := folders.Delete(id, mode, notes)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	// Сначала заметки: если упадёт сохранение папок, заметки не окажутся в несуществующей папке
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := folders.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	for _, noteID := range trashed {
+		searchIndex.Remove(login, search.KindNote, noteID)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func moveNoteToFolder(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req MoveNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if err := checkFolderExists(login, req.FolderID); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.MoveToFolder(id, req.FolderID)
+	})
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type HealthResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Отметка фоновой задачи, как у очистки сессий: задача ставит её в начале каждого прохода
+type heartbeat struct {
+	what     string // для текста ошибки: "очистка корзины"
+	interval time.Duration
+	last     atomic.Int64 // unix-время; 0 — задача выключена настройкой
+}
+
+func (h *heartbeat) beat() {
+	h.last.Store(time.Now().Unix())
+}
+
+func (h *heartbeat) check() error {
+	unix := h.last.Load()
+	if unix == 0 {
+		return nil
+	}
+	last := time.Unix(unix, 0)
+	if time.Since(last) > 2*h.interval {
+		return fmt.Errorf("%s не запускалась с %s", h.what, last.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Liveness: процесс жив и отвечает
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthResponse{Status: "ok"})
+}
+
+// Readiness: хранилище доступно и фоновые задачи работают
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func() error{
+		"data_dir":        authSystem.CheckDataDir,
+		"users_store":     authSystem.CheckUsersFile,
+		"session_cleanup": authSystem.CheckCleanup,
+		"trash_purge":     trashBeat.check,
+		"auto_archive":    archiveBeat.check,
+	}
+
+	resp := HealthResponse{Status: "ok", Components: make(map[string]ComponentStatus)}
+	for name, check := range checks {
+		if err := check(); err != nil {
+			resp.Status = "fail"
+			resp.Components[name] = ComponentStatus{Status: "fail", Error: err.Error()}
+			continue
+		}
+		resp.Components[name] = ComponentStatus{Status: "ok"}
+	}
+
+	writeHealth(w, resp)
+}
+
+func writeHealth(w http.ResponseWriter, resp HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sptodo/i18n"
+)
+
+type CatalogResponse struct {
+	Lang      string            `json:"lang"`
+	Languages []string          `json:"languages"`
+	Messages  map[string]string `json:"messages"`
+}
+
+// Язык по Accept-Language; requireAuth потом заменит его на выбранный пользователем
+func withLang(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "lang", lang)))
+	})
+}
+
+func requestLang(r *http.Request) string {
+	if lang, ok := r.Context().Value("lang").(string); ok {
+		return lang
+	}
+	return i18n.Default
+}
+
+// Каталог сообщений для фронтенда. ?lang= имеет приоритет над остальным
+func handleGetCatalog(w http.ResponseWriter, r *http.Request) {
+	lang := requestLang(r)
+	if cookie, err := r.Cookie("session_id"); err == nil {
+		if login, err := authSystem.GetLogin(cookie.Value); err == nil {
+			if userLang := authSystem.UserLang(login); userLang != "" {
+				lang = userLang
+			}
+		}
+	}
+	if q := r.URL.Query().Get("lang"); i18n.Supported(q) {
+		lang = q
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CatalogResponse{
+		Lang:      lang,
+		Languages: i18n.Languages(),
+		Messages:  i18n.Catalog(lang),
+	})
+}
+
+func handleSetLanguage(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req struct {
+		Lang string `json:"lang"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+
+	// Пустая строка сбрасывает выбор обратно на Accept-Language
+	if req.Lang != "" && !i18n.Supported(req.Lang) {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.invalid_language", map[string]string{"lang": "unsupported"})
+		return
+	}
+
+	if err := authSystem.SetUserLang(login, req.Lang); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/note"
+	"strings"
+)
+
+type RewriteLinksRequest struct {
+	From string `json:"from"` // прежний заголовок заметки
+}
+
+type RewriteLinksResponse struct {
+	Notes []int `json:"notes"` // какие заметки изменились
+	Links int   `json:"links"` // сколько ссылок переписано
+}
+
+func getBacklinks(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if _, err := notes.Find(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes.Backlinks(id).Previews(note.DefaultExcerpt))
+}
+
+func getBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notes.BrokenLinks())
+}
+
+// После переименования ссылки [[Старый заголовок]] становятся битыми — этот запрос
+// переводит их на текущий заголовок заметки. Каждая изменённая заметка получает версию в истории.
+func rewriteLinks(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req RewriteLinksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	req.From = strings.TrimSpace(req.From)
+	if req.From == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"from": "required"})
+		return
+	}
+
+	notes, revs, ok := loadNoteRevisions(w, r, id)
+	if !ok {
+		return
+	}
+	// Переписываем на заголовок, который видел клиент: если заметку с тех пор
+	// переименовали ещё раз, ссылки уехали бы на незнакомое ему имя
+	target, _ := notes.Find(id)
+	if !checkIfMatch(w, r, target.Version) {
+		return
+	}
+	to := target.LinkTarget()
+
+	resp := RewriteLinksResponse{Notes: []int{}}
+	for i := range notes {
+		n := &notes[i]
+		if n.DeletedAt != nil || !n.CanRewriteLink(req.From, id) {
+			continue
+		}
+		content, count := note.RewriteLinks(n.Content, req.From, to)
+		if count == 0 {
+			continue
+		}
+
+		revs.Before(*n)
+		if err := notes.Update(n.ID, n.Title, content); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		revs.Record(*n)
+
+		resp.Notes = append(resp.Notes, n.ID)
+		resp.Links += count
+	}
+
+	if len(resp.Notes) > 0 {
+		if err := notes.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		if err := revs.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		for _, changed := range resp.Notes {
+			n, _ := notes.Find(changed)
+			searchIndex.Put(login, noteDoc(*n))
+		}
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/todo"
+	"strconv"
+)
+
+type AddListRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func checkListExists(login string, id int) error {
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		return err
+	}
+	_, err := lists.Find(id)
+	return err
+}
+
+// Список, в который кладут задачу: существует и не в архиве
+func checkListWritable(login string, id int) error {
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		return err
+	}
+	return lists.Writable(id)
+}
+
+func getLists(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, lists)
+}
+
+func getList(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	list, err := lists.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+func addList(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req AddListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if req.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	created, err := lists.Add(req.Name, req.Color)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := lists.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/lists/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func patchList(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var patch todo.ListPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	updated, err := lists.Update(id, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := lists.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Задачи удалённого списка не пропадают, а уходят во Входящие
+func deleteList(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var lists todo.Lists
+	if err := lists.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := lists.Delete(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	todos.MoveList(id, todo.InboxID)
+
+	// Сначала задачи: если упадём между записями, список останется, а задачи уже будут во Входящих
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := lists.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getListTodos(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := checkListExists(login, id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeTodos(w, r, todos.InList(id))
+}
@@ -0,0 +1,17 @@
+package server
+
+import "sync"
+
+// Данные пользователя хранятся в JSON-файлах, и каждое изменение — это загрузить,
+// поменять, записать. Два таких цикла одновременно затирают друг друга, поэтому
+// обработчики (см. requireAuth) и фоновые задачи работают с файлами под замком пользователя.
+var userLocks sync.Map // login -> *sync.Mutex
+
+// Берёт замок пользователя и возвращает функцию, которая его отпускает:
+// defer lockUser(login)()
+func lockUser(login string) func() {
+	v, _ := userLocks.LoadOrStore(login, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
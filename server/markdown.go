@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/note"
+)
+
+// Заметка вместе с отрендеренным Markdown — ответ на ?format=html
+type NoteHTML struct {
+	note.Note
+	HTML string `json:"html"`
+}
+
+type SetCheckboxRequest struct {
+	Checked bool `json:"checked"`
+}
+
+// Отдаёт заметку в запрошенном формате: без ?format — как есть, html — с полем html
+func writeNote(w http.ResponseWriter, r *http.Request, status int, n note.Note) {
+	switch r.URL.Query().Get("format") {
+	case "", "markdown":
+		w.Header().Set("ETag", etag(n.Version))
+		writeJSON(w, status, n)
+	case "html":
+		w.Header().Set("ETag", etag(n.Version))
+		writeJSON(w, status, NoteHTML{Note: n, HTML: note.RenderHTML(n.Content)})
+	default:
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"format": "invalid"})
+	}
+}
+
+// Чекбокс "- [ ]" меняется прямо в тексте заметки. Номер берётся из data-checkbox,
+// поэтому нужен If-Match: по устаревшему HTML можно отметить не тот пункт.
+func setNoteCheckbox(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	index, ok := pathID(w, r, "n")
+	if !ok {
+		return
+	}
+
+	var req SetCheckboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, n.Version) {
+		return
+	}
+
+	content, err := note.SetCheckbox(n.Content, index, req.Checked)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var revs note.Revisions
+	if err := revs.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	before := *n
+	revs.Before(*n)
+	if err := notes.Update(id, n.Title, content); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	revs.Record(*n)
+
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := revs.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	searchIndex.Put(login, noteDoc(*n))
+	syncLinkedTodos(login, before, *n)
+
+	writeNote(w, r, http.StatusOK, *n)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sptodo/metrics"
+	"sptodo/note"
+	"sptodo/todo"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec(
+		"sptodo_http_requests_total",
+		"Количество HTTP-запросов по маршруту, методу и статусу.",
+		"route", "method", "status",
+	)
+	httpDuration = metrics.NewHistogramVec(
+		"sptodo_http_request_duration_seconds",
+		"Время обработки HTTP-запросов.",
+		nil, "route", "method", "status",
+	)
+	loginAttempts = metrics.NewCounterVec(
+		"sptodo_login_attempts_total",
+		"Попытки входа по результату.",
+		"result",
+	)
+)
+
+// Обход файлов всех пользователей стоит O(пользователей) чтений с диска,
+// поэтому счётчики задач и заметок пересчитываются не чаще раза в gaugeTTL
+const gaugeTTL = time.Minute
+
+func init() {
+	metrics.NewGaugeFunc("sptodo_active_sessions", "Количество активных сессий.", func() float64 {
+		return float64(authSystem.SessionCount())
+	})
+	metrics.NewGaugeFunc("sptodo_registered_users", "Количество зарегистрированных пользователей.", func() float64 {
+		return float64(authSystem.UserCount())
+	})
+	metrics.NewGaugeFunc("sptodo_todos", "Количество задач вне корзины и архива.", cachedGauge(gaugeTTL, func() float64 {
+		total := 0
+		for _, login := range authSystem.Logins() {
+			total += liveTodos(login)
+		}
+		return float64(total)
+	}))
+	metrics.NewGaugeFunc("sptodo_notes", "Количество заметок вне корзины.", cachedGauge(gaugeTTL, func() float64 {
+		total := 0
+		for _, login := range authSystem.Logins() {
+			total += liveNotes(login)
+		}
+		return float64(total)
+	}))
+}
+
+// Файлы читаем под замком пользователя: чтение посреди записи увидит обрезанный JSON.
+// Ошибка не роняет сбор метрик, но и молча пользователя не теряем.
+func liveTodos(login string) int {
+	defer lockUser(login)()
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		log.Printf("метрика sptodo_todos, %s: %v", login, err)
+		return 0
+	}
+	return len(todos.Alive().Archived(false))
+}
+
+func liveNotes(login string) int {
+	defer lockUser(login)()
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		log.Printf("метрика sptodo_notes, %s: %v", login, err)
+		return 0
+	}
+	return len(notes.Alive())
+}
+
+// Запоминает значение fn на ttl: частые сборы метрик не читают файлы заново
+func cachedGauge(ttl time.Duration, fn func() float64) func() float64 {
+	var (
+		mu      sync.Mutex
+		value   float64
+		expires time.Time
+	)
+	return func() float64 {
+		mu.Lock()
+		defer mu.Unlock()
+		if now := time.Now(); now.After(expires) {
+			value = fn()
+			expires = now.Add(ttl)
+		}
+		return value
+	}
+}
+
+// Запоминает статус ответа для метрик
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		// ServeMux записывает сработавший шаблон в r.Pattern
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.status)
+		httpRequests.Inc(route, r.Method, status)
+		httpDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	})
+}
+
+// Метрики отдаются либо на отдельном адресе (SPTODO_METRICS_ADDR),
+// либо на основном под токеном (SPTODO_METRICS_TOKEN). Без настроек эндпоинт выключен.
+// Адрес занимается сразу, чтобы ошибка вернулась из Start, а не уронила процесс позже.
+func configureMetrics(mux *http.ServeMux) error {
+	if addr := os.Getenv("SPTODO_METRICS_ADDR"); addr != "" {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("SPTODO_METRICS_ADDR: %w", err)
+		}
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", metrics.Handler())
+		go func() {
+			if err := http.Serve(ln, metricsMux); err != nil {
+				log.Printf("метрики %s: %v", addr, err)
+			}
+		}()
+		return nil
+	}
+
+	if token := os.Getenv("SPTODO_METRICS_TOKEN"); token != "" {
+		mux.Handle("GET /metrics", requireToken(token, metrics.Handler()))
+	}
+	return nil
+}
+
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "error.token_required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/note"
+)
+
+type SetColorRequest struct {
+	Color string `json:"color"` // пустая строка убирает цвет
+}
+
+// Общая часть запросов, которые меняют одно поле заметки: загрузить, изменить, сохранить, отдать
+func changeNote(w http.ResponseWriter, r *http.Request, change func(notes note.Notes, id int) (*note.Note, error)) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	n, err := change(notes, id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeNote(w, r, http.StatusOK, *n)
+}
+
+func pinNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.SetPinned(id, true)
+	})
+}
+
+func unpinNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.SetPinned(id, false)
+	})
+}
+
+func favoriteNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.SetFavorite(id, true)
+	})
+}
+
+func unfavoriteNote(w http.ResponseWriter, r *http.Request) {
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.SetFavorite(id, false)
+	})
+}
+
+func setNoteColor(w http.ResponseWriter, r *http.Request) {
+	var req SetColorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+
+	changeNote(w, r, func(notes note.Notes, id int) (*note.Note, error) {
+		return notes.SetColor(id, req.Color)
+	})
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"slices"
+	"sptodo/note"
+	"sptodo/relation"
+	"sptodo/todo"
+	"strconv"
+)
+
+// Загружает задачи и заметки и проверяет, что обе стороны связи есть
+func loadTodoNote(w http.ResponseWriter, r *http.Request, todoID, noteID int) (todo.Todos, note.Notes, bool) {
+	login := r.Context().Value("user").(string)
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+	if _, err := todos.Find(todoID); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+	if _, err := notes.Find(noteID); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+	return todos, notes, true
+}
+
+func getTodoNotes(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if _, err := todos.Find(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	linked := note.Notes{}
+	for _, noteID := range rels.NotesOf(id) {
+		if n, err := notes.Find(noteID); err == nil {
+			linked = append(linked, *n)
+		}
+	}
+	writeJSON(w, http.StatusOK, linked.Previews(note.DefaultExcerpt))
+}
+
+func getNoteTodos(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if _, err := notes.Find(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	linked := todo.Todos{}
+	for _, todoID := range rels.TodosOf(id) {
+		if task, err := todos.Find(todoID); err == nil {
+			linked = append(linked, *task)
+		}
+	}
+	writeJSON(w, http.StatusOK, linked)
+}
+
+func linkTodoNote(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	noteID, ok := pathID(w, r, "nid")
+	if !ok {
+		return
+	}
+
+	if _, _, ok := loadTodoNote(w, r, id, noteID); !ok {
+		return
+	}
+
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	created, err := rels.Add(id, noteID)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := rels.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/todos/"+strconv.Itoa(id)+"/notes/"+strconv.Itoa(noteID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func unlinkTodoNote(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	noteID, ok := pathID(w, r, "nid")
+	if !ok {
+		return
+	}
+
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := rels.Remove(id, noteID); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := rels.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Создаёт задачи из невыполненных пунктов-чекбоксов заметки. Пункты, из которых
+// задачи уже созданы, пропускаются, так что запрос можно повторять после правки заметки.
+func createCheckboxTodos(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	linked := make(map[int]bool)
+	for _, box := range matchCheckboxes(n.Content, rels.Checkboxes(id)) {
+		linked[box.Index] = true
+	}
+
+	before := len(todos)
+	created := todo.Todos{}
+	for _, box := range note.Checkboxes(n.Content) {
+		if box.Checked || box.Text == "" || linked[box.Index] {
+			continue
+		}
+		task := todos.Add(box.Text)
+		rels.AddCheckbox(task.ID, id, box.Index, box.Text)
+		created = append(created, task)
+	}
+
+	if len(created) > 0 {
+		if err := todos.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		if err := rels.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		indexNewTodos(login, todos, before)
+	}
+
+	// Все пункты уже со своими задачами — ничего не создано
+	status := http.StatusCreated
+	if len(created) == 0 {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, created)
+}
+
+// Переносит состояние задач ids в пункты-чекбоксы, из которых они созданы.
+// Задача уже сохранена, поэтому ошибки здесь только пишем в лог.
+func syncCheckboxes(login string, todos todo.Todos, ids ...int) {
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		log.Printf("чекбоксы заметок %s: %v", login, err)
+		return
+	}
+
+	var notes note.Notes
+	var revs note.Revisions
+	loaded := false
+	var changed []int
+
+	for i := range rels {
+		rel := &rels[i]
+		if rel.Checkbox == nil || !slices.Contains(ids, rel.TodoID) {
+			continue
+		}
+		task, err := todos.Find(rel.TodoID)
+		if err != nil {
+			continue
+		}
+
+		if !loaded {
+			if err := notes.Load(login); err != nil {
+				log.Printf("чекбоксы заметок %s: %v", login, err)
+				return
+			}
+			if err := revs.Load(login); err != nil {
+				log.Printf("чекбоксы заметок %s: %v", login, err)
+				return
+			}
+			loaded = true
+		}
+
+		n, err := notes.Find(rel.NoteID)
+		if err != nil {
+			continue
+		}
+		box := matchCheckboxes(n.Content, rels.Checkboxes(n.ID))[rel]
+		if box == nil || box.Checked == task.Completed {
+			continue
+		}
+		*rel.Checkbox = box.Index
+
+		content, err := note.SetCheckbox(n.Content, box.Index, task.Completed)
+		if err != nil {
+			continue
+		}
+		revs.Before(*n)
+		notes.Update(n.ID, n.Title, content)
+		revs.Record(*n)
+		changed = append(changed, n.ID)
+	}
+
+	if len(changed) == 0 {
+		return
+	}
+	if err := notes.Save(login); err != nil {
+		log.Printf("чекбоксы заметок %s: %v", login, err)
+		return
+	}
+	if err := revs.Save(login); err != nil {
+		log.Printf("чекбоксы заметок %s: %v", login, err)
+		return
+	}
+	if err := rels.Save(login); err != nil {
+		log.Printf("чекбоксы заметок %s: %v", login, err)
+		return
+	}
+	for _, id := range changed {
+		n, _ := notes.Find(id)
+		searchIndex.Put(login, noteDoc(*n))
+	}
+}
+
+// Пункты для всех связей-чекбоксов заметки. Ищем разом, а не по одной связи:
+// иначе две связи с одинаковым текстом найдут один и тот же пункт.
+func matchCheckboxes(content string, rels []*relation.Relation) map[*relation.Relation]*note.Checkbox {
+	refs := make([]note.CheckboxRef, len(rels))
+	for i, rel := range rels {
+		refs[i] = note.CheckboxRef{Index: *rel.Checkbox, Text: rel.CheckboxText}
+	}
+	result := make(map[*relation.Relation]*note.Checkbox)
+	for i, box := range note.MatchCheckboxes(content, refs) {
+		if box != nil {
+			result[rels[i]] = box
+		}
+	}
+	return result
+}
+
+// Обратная сторона syncCheckboxes: пункты, которые между prev и n отметили,
+// выполняют свои задачи, а с которых сняли отметку — открывают снова
+func syncLinkedTodos(login string, prev, n note.Note) {
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		log.Printf("задачи из чекбоксов %s: %v", login, err)
+		return
+	}
+
+	linked := rels.Checkboxes(n.ID)
+	was := matchCheckboxes(prev.Content, linked)
+	boxes := matchCheckboxes(n.Content, linked)
+	checked := make(map[int]bool) // ID задачи -> новое состояние
+	for _, rel := range linked {
+		if box, old := boxes[rel], was[rel]; box != nil && old != nil && box.Checked != old.Checked {
+			checked[rel.TodoID] = box.Checked
+		}
+	}
+	if len(checked) == 0 {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		log.Printf("задачи из чекбоксов %s: %v", login, err)
+		return
+	}
+
+	before := len(todos)
+	changed := false
+	for id, checked := range checked {
+		task, err := todos.Find(id)
+		if err != nil || task.Completed == checked {
+			continue
+		}
+		if checked {
+			_, err = todos.Complete(id)
+		} else {
+			err = todos.Reopen(id)
+		}
+		if err == nil {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		log.Printf("задачи из чекбоксов %s: %v", login, err)
+		return
+	}
+	indexNewTodos(login, todos, before)
+}
+
+// Убирает связи задач и заметок, удалённых насовсем
+func forgetRelations(login string, todoIDs, noteIDs []int) error {
+	var rels relation.Relations
+	if err := rels.Load(login); err != nil {
+		return err
+	}
+	if !rels.Forget(todoIDs, noteIDs) {
+		return nil
+	}
+	return rels.Save(login)
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sptodo/note"
+	"strconv"
+)
+
+type DiffResponse struct {
+	From      int             `json:"from"`
+	To        int             `json:"to"`
+	FromTitle string          `json:"from_title"`
+	ToTitle   string          `json:"to_title"`
+	Lines     []note.DiffLine `json:"lines"`
+}
+
+func configureRevisions() error {
+	v := os.Getenv("SPTODO_NOTE_REVISIONS")
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("SPTODO_NOTE_REVISIONS: ожидается положительное число, получено %q", v)
+	}
+	note.MaxRevisions = n
+	return nil
+}
+
+// Загружает заметки и версии и проверяет, что заметка есть. У старой заметки ещё нет
+// версий — текущее состояние сразу сохраняется первой, чтобы её можно было открыть и восстановить.
+func loadNoteRevisions(w http.ResponseWriter, r *http.Request, id int) (note.Notes, note.Revisions, bool) {
+	login := r.Context().Value("user").(string)
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+
+	var revs note.Revisions
+	if err := revs.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return nil, nil, false
+	}
+	if revs.Before(*n) {
+		if err := revs.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return nil, nil, false
+		}
+	}
+	return notes, revs, true
+}
+
+func getRevisions(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	_, revs, ok := loadNoteRevisions(w, r, id)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, revs.Of(id))
+}
+
+func getRevision(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	rev, ok := pathID(w, r, "rev")
+	if !ok {
+		return
+	}
+
+	_, revs, ok := loadNoteRevisions(w, r, id)
+	if !ok {
+		return
+	}
+
+	found, err := revs.Find(id, rev)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, found)
+}
+
+// ?from=1&to=3; без to — с последней версией
+func getRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	from, err := strconv.Atoi(query.Get("from"))
+	if err != nil {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_id", map[string]string{"from": "invalid"})
+		return
+	}
+
+	_, revs, ok := loadNoteRevisions(w, r, id)
+	if !ok {
+		return
+	}
+
+	to := 0
+	if v := query.Get("to"); v != "" {
+		if to, err = strconv.Atoi(v); err != nil {
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_id", map[string]string{"to": "invalid"})
+			return
+		}
+	} else if latest := revs.Of(id); len(latest) > 0 {
+		to = latest[0].Rev
+	}
+
+	a, err := revs.Find(id, from)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	b, err := revs.Find(id, to)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, DiffResponse{
+		From:      a.Rev,
+		To:        b.Rev,
+		FromTitle: a.Title,
+		ToTitle:   b.Title,
+		Lines:     note.DiffLines(a.Content, b.Content),
+	})
+}
+
+// Восстановление — обычная правка: текущее состояние остаётся в истории
+func restoreRevision(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	rev, ok := pathID(w, r, "rev")
+	if !ok {
+		return
+	}
+
+	notes, revs, ok := loadNoteRevisions(w, r, id)
+	if !ok {
+		return
+	}
+
+	// Откат затирает текущий текст — как и PUT, только поверх той версии, которую видел клиент
+	n, _ := notes.Find(id)
+	if !checkIfMatch(w, r, n.Version) {
+		return
+	}
+	found, err := revs.Find(id, rev)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	revs.Before(*n)
+	if err := notes.Update(id, found.Title, found.Content); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	revs.Record(*n)
+
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := revs.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	searchIndex.Put(login, noteDoc(*n))
+
+	writeNote(w, r, http.StatusOK, *n)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"sptodo/note"
+	"sptodo/search"
+	"sptodo/todo"
+	"strconv"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Индекс для /api/search. Строится лениво при первом поиске пользователя,
+// а обработчики, меняющие тексты, обновляют его после успешного сохранения.
+var searchIndex = search.NewIndex(loadSearchDocs)
+
+func loadSearchDocs(login string) ([]search.Doc, error) {
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		return nil, err
+	}
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		return nil, err
+	}
+
+	docs := make([]search.Doc, 0, len(notes)+len(todos))
+	for _, n := range notes.Alive() {
+		docs = append(docs, noteDoc(n))
+	}
+	for _, t := range todos.Alive() {
+		docs = append(docs, todoDoc(t))
+	}
+	return docs, nil
+}
+
+func noteDoc(n note.Note) search.Doc {
+	return search.Doc{Kind: search.KindNote, ID: n.ID, Title: n.Title, Body: n.Content}
+}
+
+func todoDoc(t todo.Todo) search.Doc {
+	return search.Doc{Kind: search.KindTodo, ID: t.ID, Title: t.Title}
+}
+
+// Задачи, появившиеся при изменении (следующие повторения), начиная с индекса from
+func indexNewTodos(login string, todos todo.Todos, from int) {
+	for _, t := range todos[from:] {
+		searchIndex.Put(login, todoDoc(t))
+	}
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+	query := r.URL.Query()
+
+	q := query.Get("q")
+	if q == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.query_required", map[string]string{"q": "required"})
+		return
+	}
+
+	limit := defaultSearchLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"limit": "invalid"})
+			return
+		}
+		limit = n
+	}
+
+	results, err := searchIndex.Search(login, q, limit)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
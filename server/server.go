@@ -4,26 +4,48 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"os"
+	"net/url"
 	"sptodo/auth"
 	"sptodo/note"
+	"sptodo/search"
 	"sptodo/todo"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type AddTodoRequest struct {
-	Title string `json:"title"`
+	Title    string `json:"title"`
+	Due      string `json:"due"`
+	TZ       string `json:"tz"`
+	RemindAt string `json:"remind_at"`
+
+	Recurrence *todo.Recurrence `json:"recurrence"`
+	Priority   string           `json:"priority"`
+	ListID     int              `json:"list_id"` // 0 — во Входящие
+	Tags       []int            `json:"tags"`
+}
+
+type ReorderTodoRequest struct {
+	ID     int `json:"id"`
+	PrevID int `json:"prev_id"` // 0 — задача становится первой
+	NextID int `json:"next_id"` // 0 — задача становится последней
 }
 
 type AddNoteRequest struct {
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Tags     []int  `json:"tags"`
+	FolderID int    `json:"folder_id"` // 0 — в корень
+	// Шаблон заполняет пустые title и content; подстановки раскрываются в поясе TZ
+	TemplateID int    `json:"template_id"`
+	TZ         string `json:"tz"`
 }
 
 type UpdateNoteRequest struct {
 	Title   string `json:"title"`
 	Content string `json:"content"`
+	Tags    *[]int `json:"tags"` // nil — теги не меняются
 }
 
 var authSystem *auth.Auth // ← глобальная переменная
@@ -34,67 +56,226 @@ func Start() error {
 	if err != nil {
 		return err
 	}
+	if err := configureRevisions(); err != nil {
+		return err
+	}
+	if err := configureAttachments(); err != nil {
+		return err
+	}
+	if err := startTrashPurge(); err != nil {
+		return err
+	}
+	if err := startAutoArchive(); err != nil {
+		return err
+	}
 
 	mux := http.NewServeMux()
 
+	// Проверки для балансировщика
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+
 	// Публичные эндпоинты
 	mux.HandleFunc("POST /api/register", handleRegister)
 	mux.HandleFunc("POST /api/login", handleLogin)
 	mux.HandleFunc("POST /api/account", requireAuth(handleDeleteAccount))
+	mux.HandleFunc("PUT /api/account/language", requireAuth(handleSetLanguage))
+	mux.HandleFunc("GET /api/i18n", handleGetCatalog)
 
 	// Защищённые эндпоинты
 	mux.HandleFunc("GET /api/todos", requireAuth(getTodos))
 	mux.HandleFunc("POST /api/todos", requireAuth(addTodo))
+	mux.HandleFunc("GET /api/todos/reminders", requireAuth(getReminders))
+	mux.HandleFunc("POST /api/todos/reorder", requireAuth(reorderTodo))
+	mux.HandleFunc("GET /api/todos/{id}", requireAuth(getTodo))
+	mux.HandleFunc("PATCH /api/todos/{id}", requireAuth(patchTodo))
 	mux.HandleFunc("PUT /api/todos/{id}/complete", requireAuth(completeTodo))
 	mux.HandleFunc("DELETE /api/todos/{id}", requireAuth(deleteTodo))
+	mux.HandleFunc("PUT /api/todos/{id}/archive", requireAuth(archiveTodo))
+	mux.HandleFunc("GET /api/todos/{id}/notes", requireAuth(getTodoNotes))
+	mux.HandleFunc("PUT /api/todos/{id}/notes/{nid}", requireAuth(linkTodoNote))
+	mux.HandleFunc("DELETE /api/todos/{id}/notes/{nid}", requireAuth(unlinkTodoNote))
+	mux.HandleFunc("DELETE /api/todos/{id}/archive", requireAuth(unarchiveTodo))
+
+	// Подзадачи
+	mux.HandleFunc("GET /api/todos/{id}/subtasks", requireAuth(getSubtasks))
+	mux.HandleFunc("POST /api/todos/{id}/subtasks", requireAuth(addSubtask))
+	mux.HandleFunc("PATCH /api/todos/{id}/subtasks/{sid}", requireAuth(patchSubtask))
+	mux.HandleFunc("DELETE /api/todos/{id}/subtasks/{sid}", requireAuth(deleteSubtask))
+
+	mux.HandleFunc("GET /api/search", requireAuth(handleSearch))
+
+	// Корзина
+	mux.HandleFunc("GET /api/trash", requireAuth(getTrash))
+	mux.HandleFunc("DELETE /api/trash", requireAuth(emptyTrash))
+	mux.HandleFunc("POST /api/trash/todos/{id}/restore", requireAuth(restoreTodo))
+	mux.HandleFunc("DELETE /api/trash/todos/{id}", requireAuth(purgeTodo))
+	mux.HandleFunc("POST /api/trash/notes/{id}/restore", requireAuth(restoreNote))
+	mux.HandleFunc("DELETE /api/trash/notes/{id}", requireAuth(purgeNote))
+
+	// Теги — общие для задач и заметок
+	mux.HandleFunc("GET /api/tags", requireAuth(getTags))
+	mux.HandleFunc("POST /api/tags", requireAuth(addTag))
+	mux.HandleFunc("GET /api/tags/{id}", requireAuth(getTag))
+	mux.HandleFunc("PATCH /api/tags/{id}", requireAuth(patchTag))
+	mux.HandleFunc("DELETE /api/tags/{id}", requireAuth(deleteTag))
+	mux.HandleFunc("GET /api/tags/{id}/items", requireAuth(getTagItems))
+
+	// Списки задач
+	mux.HandleFunc("GET /api/lists", requireAuth(getLists))
+	mux.HandleFunc("POST /api/lists", requireAuth(addList))
+	mux.HandleFunc("GET /api/lists/{id}", requireAuth(getList))
+	mux.HandleFunc("PATCH /api/lists/{id}", requireAuth(patchList))
+	mux.HandleFunc("DELETE /api/lists/{id}", requireAuth(deleteList))
+	mux.HandleFunc("GET /api/lists/{id}/todos", requireAuth(getListTodos))
 
 	//Заметки
+	mux.HandleFunc("GET /api/folders", requireAuth(getFolders))
+	mux.HandleFunc("GET /api/folders/tree", requireAuth(getFolderTree))
+	mux.HandleFunc("POST /api/folders", requireAuth(addFolder))
+	mux.HandleFunc("PATCH /api/folders/{id}", requireAuth(patchFolder))
+	mux.HandleFunc("DELETE /api/folders/{id}", requireAuth(deleteFolder))
+	mux.HandleFunc("GET /api/templates", requireAuth(getTemplates))
+	mux.HandleFunc("POST /api/templates", requireAuth(addTemplate))
+	mux.HandleFunc("GET /api/templates/{id}", requireAuth(getTemplate))
+	mux.HandleFunc("PATCH /api/templates/{id}", requireAuth(patchTemplate))
+	mux.HandleFunc("DELETE /api/templates/{id}", requireAuth(deleteTemplate))
+
 	mux.HandleFunc("GET /api/notes", requireAuth(getNotes))
+	mux.HandleFunc("GET /api/notes/broken-links", requireAuth(getBrokenLinks))
 	mux.HandleFunc("GET /api/notes/{id}", requireAuth(getNote))
 	mux.HandleFunc("POST /api/notes", requireAuth(addNote))
 	mux.HandleFunc("PUT /api/notes/{id}", requireAuth(updateNote))
 	mux.HandleFunc("DELETE /api/notes/{id}", requireAuth(deleteNote))
+	mux.HandleFunc("PUT /api/notes/{id}/archive", requireAuth(archiveNote))
+	mux.HandleFunc("DELETE /api/notes/{id}/archive", requireAuth(unarchiveNote))
+	mux.HandleFunc("PUT /api/notes/{id}/pin", requireAuth(pinNote))
+	mux.HandleFunc("DELETE /api/notes/{id}/pin", requireAuth(unpinNote))
+	mux.HandleFunc("PUT /api/notes/{id}/favorite", requireAuth(favoriteNote))
+	mux.HandleFunc("DELETE /api/notes/{id}/favorite", requireAuth(unfavoriteNote))
+	mux.HandleFunc("PUT /api/notes/{id}/color", requireAuth(setNoteColor))
+	mux.HandleFunc("PUT /api/notes/{id}/folder", requireAuth(moveNoteToFolder))
+	mux.HandleFunc("GET /api/notes/{id}/backlinks", requireAuth(getBacklinks))
+	mux.HandleFunc("POST /api/notes/{id}/rewrite-links", requireAuth(rewriteLinks))
+	mux.HandleFunc("GET /api/notes/{id}/attachments", requireAuth(getAttachments))
+	mux.HandleFunc("POST /api/notes/{id}/attachments", requireAuthStream(addAttachment))
+	mux.HandleFunc("GET /api/notes/{id}/attachments/{aid}", requireAuthStream(downloadAttachment))
+	mux.HandleFunc("DELETE /api/notes/{id}/attachments/{aid}", requireAuth(deleteAttachment))
+	mux.HandleFunc("PUT /api/notes/{id}/checkboxes/{n}", requireAuth(setNoteCheckbox))
+	mux.HandleFunc("POST /api/notes/{id}/checkboxes/todos", requireAuth(createCheckboxTodos))
+	mux.HandleFunc("GET /api/notes/{id}/todos", requireAuth(getNoteTodos))
+	mux.HandleFunc("GET /api/notes/{id}/revisions", requireAuth(getRevisions))
+	mux.HandleFunc("GET /api/notes/{id}/revisions/diff", requireAuth(getRevisionDiff))
+	mux.HandleFunc("GET /api/notes/{id}/revisions/{rev}", requireAuth(getRevision))
+	mux.HandleFunc("POST /api/notes/{id}/revisions/{rev}/restore", requireAuth(restoreRevision))
+
+	// Неизвестные пути API тоже отвечают JSON-ошибкой, а не страницей FileServer
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		if allow := allowedMethods(mux, r); len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, CodeMethod, "error.method_not_allowed")
+			return
+		}
+		writeError(w, r, http.StatusNotFound, CodeNotFound, "error.route_not_found")
+	})
 
 	// Статика
 	mux.Handle("/", http.FileServer(http.Dir("./web/")))
 
-	return http.ListenAndServe(":8080", mux)
+	if err := configureMetrics(mux); err != nil {
+		return err
+	}
+
+	return http.ListenAndServe(":8080", withRequestID(withLang(instrument(mux))))
+}
+
+// Catch-all "/api/" перехватывает и известные пути с чужим методом — ServeMux тогда
+// не отвечает 405 сам. Проверяем, какие методы для этого пути всё же зарегистрированы.
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allow []string
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if method == r.Method {
+			continue
+		}
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "/api/" {
+			allow = append(allow, method)
+		}
+	}
+	return allow
 }
 
 func handleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
 
 	if err := authSystem.DeleteUser(login); err != nil {
-		http.Error(w, "Ошибка удаления аккаунта: "+err.Error(), http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
 	authSystem.ClearUserSessions(login)
+	searchIndex.Drop(login)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // Тяжелая и пока что не понятная для меня функция в плане написания кода
 func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(next, true)
+}
+
+// Для загрузки и скачивания файлов: пока файл идёт по сети, остальные запросы
+// пользователя ждать не должны. Обработчик сам берёт lockUser там, где трогает JSON.
+func requireAuthStream(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(next, false)
+}
+
+func authenticate(next http.HandlerFunc, lock bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cookie, err := r.Cookie("session_id")
 		if err != nil {
-			http.Error(w, "Требуется вход", http.StatusUnauthorized)
+			writeError(w, r, http.StatusUnauthorized, CodeUnauthorized, "error.unauthorized")
 			return // ⛔ прерываем выполнение — next НЕ вызывается
 		}
 
 		login, err := authSystem.GetLogin(cookie.Value)
 		if err != nil {
-			http.Error(w, "Сессия недействительна", http.StatusUnauthorized)
+			writeDomainError(w, r, err)
 			return // ⛔ снова прерываем
 		}
-		r = r.WithContext(context.WithValue(r.Context(), "user", login))
+		ctx := context.WithValue(r.Context(), "user", login)
+		if lang := authSystem.UserLang(login); lang != "" {
+			ctx = context.WithValue(ctx, "lang", lang)
+		}
+		r = r.WithContext(ctx)
+
+		// Запросы одного пользователя к его файлам идут по очереди — см. lockUser
+		if lock {
+			defer lockUser(login)()
+		}
 
 		// 4. Вызываем оригинальный обработчик
 		next(w, r)
 	}
 }
 
+// Достаёт числовой {id} из пути; при ошибке сам отвечает клиенту
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_id", map[string]string{name: "invalid"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
 func handleRegister(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Login    string `json:"login"`
@@ -102,12 +283,12 @@ func handleRegister(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
 		return
 	}
 
 	if err := authSystem.Register(req.Login, req.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeDomainError(w, r, err)
 		return
 	}
 
@@ -120,15 +301,17 @@ func handleLogin(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
 		return
 	}
 
 	sessionID, err := authSystem.Login(req.Login, req.Password)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		loginAttempts.Inc("failure")
+		writeDomainError(w, r, err)
 		return
 	}
+	loginAttempts.Inc("success")
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_id",
@@ -146,81 +329,381 @@ func getTodos(w http.ResponseWriter, r *http.Request) {
 
 	var todos todo.Todos
 	if err := todos.Load(login); err != nil { // ← передали логин
-		http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(todos); err != nil {
-		http.Error(w, "Ошибка сериализации", http.StatusInternalServerError)
+	if list := r.URL.Query().Get("list"); list != "" {
+		listID, err := strconv.Atoi(list)
+		if err != nil {
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_id", map[string]string{"list": "invalid"})
+			return
+		}
+		todos = todos.InList(listID)
+	}
+
+	writeTodos(w, r, todos)
+}
+
+// Фильтры ?archived=, ?due=, ?tz=, ?tag= и всё, что разбирает todoQuery, — общие для всех выдач списков задач.
+// С ?limit= или ?cursor= ответ — страница {items, next_cursor}, без них — просто массив
+// всех подходящих задач, как было до пагинации.
+func writeTodos(w http.ResponseWriter, r *http.Request, todos todo.Todos) {
+	archived, err := archivedFilter(r)
+	if err != nil {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"archived": "invalid"})
+		return
+	}
+	todos = todos.Alive().Archived(archived)
+	query := r.URL.Query()
+	loc, err := todo.LoadLocation(query.Get("tz"))
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	tags, mode, err := tagFilter(r)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	todos = todos.WithTags(tags, mode)
+
+	if due := query.Get("due"); due != "" {
+		if todos, err = todos.FilterDue(due, time.Now().In(loc)); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
+
+	q, field, err := todoQuery(query, loc)
+	if err != nil {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{field: "invalid"})
+		return
+	}
+
+	// Без limit и cursor — как раньше, массив всех задач: старые клиенты не ждут страниц
+	if !query.Has("limit") && !query.Has("cursor") {
+		items, err := q.Items(todos)
+		if err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+
+	page, err := q.Apply(todos)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// Разбирает параметры выборки; при ошибке возвращает имя неверного параметра.
+// Даты — как у срока (см. todo.ParseDue); дата без времени в *_to включает весь день.
+func todoQuery(query url.Values, loc *time.Location) (todo.Query, string, error) {
+	q := todo.Query{
+		Text:   query.Get("q"),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+
+	if v := query.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, "completed", err
+		}
+		q.Completed = &completed
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > todo.MaxLimit {
+			return q, "limit", todo.ErrInvalidFilter
+		}
+		q.Limit = limit
+	}
+
+	dates := []struct {
+		name string
+		dst  **time.Time
+		end  bool
+	}{
+		{"created_from", &q.CreatedFrom, false},
+		{"created_to", &q.CreatedTo, true},
+		{"completed_from", &q.CompletedFrom, false},
+		{"completed_to", &q.CompletedTo, true},
+	}
+	for _, d := range dates {
+		v := query.Get(d.name)
+		if v == "" {
+			continue
+		}
+		at, allDay, err := todo.ParseDue(v, loc)
+		if err != nil {
+			return q, d.name, err
+		}
+		if allDay && d.end {
+			at = at.AddDate(0, 0, 1)
+		}
+		*d.dst = &at
+	}
+
+	return q, "", nil
+}
+
+func reorderTodo(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req ReorderTodoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	// Меняется ранг только перенесённой задачи
+	moved, err := todos.Move(req.ID, req.PrevID, req.NextID)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, moved)
+}
+
+func getReminders(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, todos.Alive().DueReminders(time.Now()))
+}
+
+func getTodo(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
 	}
+
+	task, err := todos.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(task.Version))
+	writeJSON(w, http.StatusOK, task)
 }
 
 func addTodo(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
 
 	if r.Header.Get("Content-Type") != "application/json" {
-		http.Error(w, "Тело запроса должно быть в формате JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeBadRequest, "error.json_required")
 		return
 	}
 
 	var req AddTodoRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Ошибка десериализации", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
 		return
 	}
 
 	if req.Title == "" {
-		http.Error(w, "Заголовок задачи не может быть пустым", http.StatusBadRequest)
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.todo_title_required", map[string]string{"title": "required"})
 		return
 	}
+	if req.ListID != 0 {
+		if err := checkListWritable(login, req.ListID); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
+	if err := checkTagsExist(login, req.Tags); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
 	var todos todo.Todos
 	if err := todos.Load(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
+		return
+	}
+	created := todos.Add(req.Title)
+
+	// Остальные поля задаются так же, как при PATCH
+	patch := todo.Patch{
+		Due:        &req.Due,
+		TZ:         &req.TZ,
+		RemindAt:   &req.RemindAt,
+		Recurrence: req.Recurrence,
+		Priority:   &req.Priority,
+	}
+	if req.ListID != 0 {
+		patch.ListID = &req.ListID
+	}
+	if req.Tags != nil {
+		patch.Tags = &req.Tags
+	}
+	updated, err := todos.Update(created.ID, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
-	todos.Add(req.Title) // игнорируем ошибку "файл не найден"
 
 	if err := todos.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	searchIndex.Put(login, todoDoc(*updated))
+
+	w.Header().Set("Location", "/api/todos/"+strconv.Itoa(updated.ID))
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusCreated, updated)
 }
 
-func deleteTodo(w http.ResponseWriter, r *http.Request) {
+func patchTodo(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
 
-	idStr := r.PathValue("id")
-	if idStr == "" {
-		http.Error(w, "Not id", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var patch todo.Patch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+
+	if patch.Title != nil && *patch.Title == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.todo_title_required", map[string]string{"title": "required"})
+		return
+	}
+
+	// Перенос в другой список
+	if patch.ListID != nil {
+		if err := checkListWritable(login, *patch.ListID); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
+	if patch.Tags != nil {
+		if err := checkTagsExist(login, *patch.Tags); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
+	current, err := todos.Find(id)
 	if err != nil {
-		http.Error(w, "Неправильный id", http.StatusBadRequest)
+		writeDomainError(w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, current.Version) {
 		return
 	}
 
+	before := len(todos)
+	updated, err := todos.Update(id, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	searchIndex.Put(login, todoDoc(*updated))
+	indexNewTodos(login, todos, before)
+	if patch.Completed != nil {
+		syncCheckboxes(login, todos, id)
+	}
+
+	w.Header().Set("ETag", etag(updated.Version))
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Версия API, в которой PUT /api/todos/{id}/complete и DELETE /api/todos/{id} адресуют задачу по ID
+const apiVersionIDs = "2"
+
+// Изначально эти два маршрута принимали позицию задачи в списке GET /api/todos, и старые
+// клиенты так и шлют. Поэтому {id} понимается как ID только с заголовком API-Version: 2,
+// без него — как индекс в списке по умолчанию (живые неархивные задачи по дате создания).
+func todoRef(w http.ResponseWriter, r *http.Request, todos todo.Todos) (int, bool) {
+	n, ok := pathID(w, r, "id")
+	if !ok || r.Header.Get("API-Version") == apiVersionIDs {
+		return n, ok
+	}
+
+	listed := todos.Alive().Archived(false)
+	listed.Sort(todo.SortCreated)
+	if n < 0 || n >= len(listed) {
+		writeDomainError(w, r, todo.ErrNotFound)
+		return 0, false
+	}
+	return listed[n].ID, true
+}
+
+func deleteTodo(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
 	var todos todo.Todos
 	if err := todos.Load(login); err != nil {
-		if !os.IsNotExist(err) {
-			http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
-			return
-		}
+		writeDomainError(w, r, err)
+		return
+	}
+
+	id, ok := todoRef(w, r, todos)
+	if !ok {
+		return
 	}
 
 	if err := todos.Delete(id); err != nil {
-		http.Error(w, "Ошибка удаления: "+err.Error(), http.StatusBadRequest)
+		writeDomainError(w, r, err)
 		return
 	}
 
 	if err := todos.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
+	searchIndex.Remove(login, search.KindTodo, id)
 
 	// 6. Отвечаем 204 No Content (успешно, без тела)
 	w.WriteHeader(http.StatusNoContent)
@@ -229,36 +712,32 @@ func deleteTodo(w http.ResponseWriter, r *http.Request) {
 func completeTodo(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
 
-	idStr := r.PathValue("id")
-	if idStr == "" {
-		http.Error(w, "Not id", http.StatusBadRequest)
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Неправильный id", http.StatusBadRequest)
+	id, ok := todoRef(w, r, todos)
+	if !ok {
 		return
 	}
 
-	var todos todo.Todos
-	if err := todos.Load(login); err != nil {
-		if !os.IsNotExist(err) {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	if err := todos.Complete(id); err != nil {
-		http.Error(w, "Ошибка завершения: "+err.Error(), http.StatusBadRequest)
+	next, err := todos.Complete(id)
+	if err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
 
 	// 5. Сохраняем
 	if err := todos.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
+	if next != nil {
+		searchIndex.Put(login, todoDoc(*next))
+	}
+	syncCheckboxes(login, todos, id)
 
 	// 6. Отвечаем 204
 	w.WriteHeader(http.StatusNoContent)
@@ -270,57 +749,66 @@ func getNotes(w http.ResponseWriter, r *http.Request) {
 
 	var notes note.Notes
 	if err := notes.Load(login); err != nil {
-		http.Error(w, "Ошибка загрузки заметок", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
-	// Оптимизация: не отправляем content в списке
-	type NotePreview struct {
-		ID        int       `json:"id"`
-		Title     string    `json:"title"`
-		CreatedAt time.Time `json:"created_at"`
-		UpdatedAt time.Time `json:"updated_at"`
-	} //Вынести в note пакет
+	tags, mode, err := tagFilter(r)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	archived, err := archivedFilter(r)
+	if err != nil {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"archived": "invalid"})
+		return
+	}
+	notes = notes.Alive().Archived(archived).WithTags(tags, mode).PinnedFirst()
 
-	previews := make([]NotePreview, len(notes))
-	for i, n := range notes {
-		previews[i] = NotePreview{
-			ID:        n.ID,
-			Title:     n.Title,
-			CreatedAt: n.CreatedAt,
-			UpdatedAt: n.UpdatedAt,
+	// ?folder= — заметки прямо в папке, 0 — в корне
+	if v := r.URL.Query().Get("folder"); v != "" {
+		folderID, err := strconv.Atoi(v)
+		if err != nil {
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_id", map[string]string{"folder": "invalid"})
+			return
 		}
+		notes = notes.InFolder(folderID)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(previews)
+	// Оптимизация: вместо content в списке только выдержка, её длина — ?excerpt=
+	length := note.DefaultExcerpt
+	if v := r.URL.Query().Get("excerpt"); v != "" {
+		length, err = strconv.Atoi(v)
+		if err != nil || length < 0 || length > note.MaxExcerpt {
+			writeErrorDetails(w, r, http.StatusBadRequest, CodeBadRequest, "error.invalid_filter", map[string]string{"excerpt": "invalid"})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, notes.Previews(length))
 }
 
 func getNote(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
-	idStr := r.PathValue("id")
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Неверный ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
 	var notes note.Notes
 	if err := notes.Load(login); err != nil {
-		http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
-	for _, n := range notes {
-		if n.ID == id {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(n)
-			return
-		}
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
 	}
 
-	http.Error(w, "Заметка не найдена", http.StatusNotFound)
+	writeNote(w, r, http.StatusOK, *n)
 }
 
 func addNote(w http.ResponseWriter, r *http.Request) {
@@ -328,96 +816,170 @@ func addNote(w http.ResponseWriter, r *http.Request) {
 
 	var req AddNoteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
 		return
 	}
 
+	if req.TemplateID != 0 {
+		if err := applyTemplate(r, login, &req); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
 	if req.Title == "" {
-		http.Error(w, "Заголовок обязателен", http.StatusBadRequest)
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.note_title_required", map[string]string{"title": "required"})
+		return
+	}
+	if err := checkTagsExist(login, req.Tags); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := checkFolderExists(login, req.FolderID); err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
 
 	var notes note.Notes
 	if err := notes.Load(login); err != nil {
-		http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
-	notes.Add(req.Title, req.Content)
+	created := notes.Add(req.Title, req.Content)
+	if req.Tags != nil {
+		notes.SetTags(created.ID, req.Tags)
+	}
+	if req.FolderID != note.RootFolderID {
+		notes.MoveToFolder(created.ID, req.FolderID)
+	}
+	// Теги и папка меняют заметку в срезе, а MoveToFolder ещё и поднимает версию:
+	// в историю, ETag и ответ идёт то, что ляжет на диск
+	n, _ := notes.Find(created.ID)
+	created = *n
+
+	var revs note.Revisions
+	if err := revs.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	revs.Record(created)
 
 	if err := notes.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := revs.Save(login); err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	searchIndex.Put(login, noteDoc(created))
+
+	w.Header().Set("Location", "/api/notes/"+strconv.Itoa(created.ID))
+	w.Header().Set("ETag", etag(created.Version))
+	writeJSON(w, http.StatusCreated, created)
 }
 
 func updateNote(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
-	idStr := r.PathValue("id")
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Неверный ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
 	var req UpdateNoteRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
 		return
 	}
 
 	if req.Title == "" {
-		http.Error(w, "Заголовок обязателен", http.StatusBadRequest)
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.note_title_required", map[string]string{"title": "required"})
 		return
 	}
+	if req.Tags != nil {
+		if err := checkTagsExist(login, *req.Tags); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+	}
 
 	var notes note.Notes
 	if err := notes.Load(login); err != nil {
-		http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
+		return
+	}
+
+	n, err := notes.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if !checkIfMatch(w, r, n.Version) {
 		return
 	}
 
+	var revs note.Revisions
+	if err := revs.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	before := *n
+	revs.Before(*n)
+
 	if err := notes.Update(id, req.Title, req.Content); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeDomainError(w, r, err)
 		return
 	}
+	if req.Tags != nil {
+		notes.SetTags(id, *req.Tags)
+	}
+	// В истории только текст: сохранение без правок (или только теги) версию не плодит
+	if n.Title != before.Title || n.Content != before.Content {
+		revs.Record(*n)
+	}
 
 	if err := notes.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := revs.Save(login); err != nil {
+		writeDomainError(w, r, err)
 		return
 	}
+	searchIndex.Put(login, noteDoc(*n))
+	// Пункты могли отметить прямо в тексте — задачи из них идут следом
+	syncLinkedTodos(login, before, *n)
 
+	w.Header().Set("ETag", etag(n.Version))
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func deleteNote(w http.ResponseWriter, r *http.Request) {
 	login := r.Context().Value("user").(string)
-	idStr := r.PathValue("id")
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Неверный ID", http.StatusBadRequest)
+	id, ok := pathID(w, r, "id")
+	if !ok {
 		return
 	}
 
 	var notes note.Notes
 	if err := notes.Load(login); err != nil {
-		http.Error(w, "Ошибка загрузки", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
 
 	if err := notes.Delete(id); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeDomainError(w, r, err)
 		return
 	}
 
 	if err := notes.Save(login); err != nil {
-		http.Error(w, "Ошибка сохранения", http.StatusInternalServerError)
+		writeDomainError(w, r, err)
 		return
 	}
+	searchIndex.Remove(login, search.KindNote, id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/todo"
+	"strconv"
+)
+
+type AddSubtaskRequest struct {
+	Title    string `json:"title"`
+	ParentID int    `json:"parent_id"` // 0 — подзадача верхнего уровня
+}
+
+// Общая часть всех обработчиков подзадач: загрузить задачи, изменить, сохранить
+func withTodos(w http.ResponseWriter, r *http.Request, fn func(todos *todo.Todos) (any, int, error)) {
+	login := r.Context().Value("user").(string)
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	before := len(todos)
+	result, status, err := fn(&todos)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		if err := todos.Save(login); err != nil {
+			writeDomainError(w, r, err)
+			return
+		}
+		// Автовыполнение могло создать следующее повторение и отметить задачу выполненной
+		indexNewTodos(login, todos, before)
+		if id, err := strconv.Atoi(r.PathValue("id")); err == nil {
+			syncCheckboxes(login, todos, id)
+		}
+	}
+
+	if result == nil {
+		w.WriteHeader(status)
+		return
+	}
+	writeJSON(w, status, result)
+}
+
+func getSubtasks(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	withTodos(w, r, func(todos *todo.Todos) (any, int, error) {
+		task, err := todos.Find(id)
+		if err != nil {
+			return nil, 0, err
+		}
+		subtasks := task.Subtasks
+		if subtasks == nil {
+			subtasks = []todo.Subtask{}
+		}
+		return subtasks, http.StatusOK, nil
+	})
+}
+
+func addSubtask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var req AddSubtaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if req.Title == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.todo_title_required", map[string]string{"title": "required"})
+		return
+	}
+
+	withTodos(w, r, func(todos *todo.Todos) (any, int, error) {
+		created, err := todos.AddSubtask(id, req.ParentID, req.Title)
+		if err != nil {
+			return nil, 0, err
+		}
+		w.Header().Set("Location", "/api/todos/"+strconv.Itoa(id)+"/subtasks/"+strconv.Itoa(created.ID))
+		return created, http.StatusCreated, nil
+	})
+}
+
+func patchSubtask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	subID, ok := pathID(w, r, "sid")
+	if !ok {
+		return
+	}
+
+	var patch todo.SubtaskPatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if patch.Title != nil && *patch.Title == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.todo_title_required", map[string]string{"title": "required"})
+		return
+	}
+
+	withTodos(w, r, func(todos *todo.Todos) (any, int, error) {
+		updated, err := todos.UpdateSubtask(id, subID, patch)
+		if err != nil {
+			return nil, 0, err
+		}
+		return updated, http.StatusOK, nil
+	})
+}
+
+func deleteSubtask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	subID, ok := pathID(w, r, "sid")
+	if !ok {
+		return
+	}
+
+	withTodos(w, r, func(todos *todo.Todos) (any, int, error) {
+		return nil, http.StatusNoContent, todos.DeleteSubtask(id, subID)
+	})
+}
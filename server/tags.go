@@ -0,0 +1,259 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/note"
+	"sptodo/tag"
+	"sptodo/todo"
+	"strconv"
+)
+
+type AddTagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Всё, что помечено одним тегом
+type TagItemsResponse struct {
+	Tag   tag.Tag    `json:"tag"`
+	Todos todo.Todos `json:"todos"`
+	Notes note.Notes `json:"notes"`
+}
+
+func checkTagsExist(login string, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		return err
+	}
+	return tags.Check(ids)
+}
+
+// Разбирает ?tag=a&tag=b&tag_mode=any|all. Без ?tag= фильтра нет.
+func tagFilter(r *http.Request) ([]int, string, error) {
+	query := r.URL.Query()
+	mode := query.Get("tag_mode")
+	if !tag.ValidMode(mode) {
+		return nil, "", tag.ErrInvalidMode
+	}
+
+	values, idValues := query["tag"], query["tag_id"]
+	if len(values) == 0 && len(idValues) == 0 {
+		return nil, mode, nil
+	}
+
+	login := r.Context().Value("user").(string)
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		return nil, "", err
+	}
+	ids, err := tags.Resolve(values)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, v := range idValues {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, "", tag.ErrNotFound
+		}
+		ids = append(ids, id)
+	}
+	return ids, mode, tags.Check(ids)
+}
+
+func getTags(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tags)
+}
+
+func getTag(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	t, err := tags.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func addTag(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req AddTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if req.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	created, err := tags.Add(req.Name, req.Color)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := tags.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/tags/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func patchTag(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var patch tag.Patch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	updated, err := tags.Update(id, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := tags.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Удалённый тег снимается со всех задач и заметок
+func deleteTag(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := tags.Delete(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	todos.RemoveTag(id)
+	notes.RemoveTag(id)
+
+	// Сначала снимаем ссылки, потом удаляем сам тег — висячих ID не останется
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := tags.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func getTagItems(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var tags tag.Tags
+	if err := tags.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	t, err := tags.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	want := []int{id}
+	writeJSON(w, http.StatusOK, TagItemsResponse{
+		Tag:   *t,
+		Todos: todos.Alive().Archived(false).WithTags(want, tag.ModeAny),
+		Notes: notes.Alive().Archived(false).WithTags(want, tag.ModeAny),
+	})
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sptodo/i18n"
+	"sptodo/note"
+	"sptodo/todo"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AddTemplateRequest struct {
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
+
+// Значения подстановок на момент создания заметки: время — в поясе клиента, день недели — на его языке
+func templateVars(r *http.Request, now time.Time) map[string]string {
+	return map[string]string{
+		"date":    now.Format("2006-01-02"),
+		"time":    now.Format("15:04"),
+		"weekday": i18n.T(requestLang(r), "weekday."+strings.ToLower(now.Weekday().String())),
+	}
+}
+
+// Раскрывает шаблон для новой заметки. Что пришло в запросе явно, то и остаётся.
+func applyTemplate(r *http.Request, login string, req *AddNoteRequest) error {
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		return err
+	}
+	t, err := templates.Find(req.TemplateID)
+	if err != nil {
+		return err
+	}
+	loc, err := todo.LoadLocation(req.TZ)
+	if err != nil {
+		return err
+	}
+
+	req.Title, req.Content = t.Apply(req.Title, req.Content, templateVars(r, time.Now().In(loc)))
+	return nil
+}
+
+func getTemplates(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, templates)
+}
+
+func getTemplate(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	t, err := templates.Find(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func addTemplate(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var req AddTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if req.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	created := templates.Add(req.Name, req.Title, req.Content)
+
+	if err := templates.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/templates/"+strconv.Itoa(created.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func patchTemplate(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var patch note.TemplatePatch
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		writeError(w, r, http.StatusBadRequest, CodeInvalidJSON, "error.invalid_json")
+		return
+	}
+	if patch.Name != nil && *patch.Name == "" {
+		writeErrorDetails(w, r, http.StatusBadRequest, CodeValidation, "error.name_required", map[string]string{"name": "required"})
+		return
+	}
+
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	updated, err := templates.Update(id, patch)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := templates.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+func deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var templates note.Templates
+	if err := templates.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := templates.Delete(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := templates.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sptodo/note"
+	"sptodo/todo"
+	"strconv"
+	"time"
+)
+
+// Сколько дней держать удалённое в корзине, меняется через SPTODO_TRASH_DAYS (0 — не чистить)
+const defaultTrashDays = 30
+
+const trashPurgeInterval = time.Hour
+
+var trashBeat = &heartbeat{what: "очистка корзины", interval: trashPurgeInterval}
+
+type TrashResponse struct {
+	Todos todo.Todos `json:"todos"`
+	Notes note.Notes `json:"notes"`
+}
+
+func startTrashPurge() error {
+	days := defaultTrashDays
+	if v := os.Getenv("SPTODO_TRASH_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("SPTODO_TRASH_DAYS: ожидается неотрицательное число, получено %q", v)
+		}
+		days = n
+	}
+	if days == 0 {
+		return nil
+	}
+
+	go func() {
+		ticker := time.NewTicker(trashPurgeInterval)
+		defer ticker.Stop()
+
+		for ; ; <-ticker.C {
+			trashBeat.beat()
+			before := time.Now().AddDate(0, 0, -days)
+			for _, login := range authSystem.Logins() {
+				unlock := lockUser(login)
+				err := purgeTrash(login, before)
+				unlock()
+				if err != nil {
+					log.Printf("очистка корзины %s: %v", login, err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Удаляет насовсем всё, что попало в корзину раньше before.
+// Вызывающий держит lockUser(login)
+func purgeTrash(login string, before time.Time) error {
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		return err
+	}
+	purgedTodos := todos.PurgeBefore(before)
+	if len(purgedTodos) > 0 {
+		if err := todos.Save(login); err != nil {
+			return err
+		}
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		return err
+	}
+	purged := notes.PurgeBefore(before)
+	if err := forgetRelations(login, purgedTodos, purged); err != nil {
+		return err
+	}
+	if len(purged) == 0 {
+		return nil
+	}
+	if err := notes.Save(login); err != nil {
+		return err
+	}
+	if err := note.CollectBlobs(login, notes); err != nil {
+		return err
+	}
+	return forgetRevisions(login, purged...)
+}
+
+// История заметки живёт, пока заметка в корзине, и удаляется вместе с ней
+func forgetRevisions(login string, ids ...int) error {
+	var revs note.Revisions
+	if err := revs.Load(login); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		revs.Forget(id)
+	}
+	return revs.Save(login)
+}
+
+func getTrash(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, TrashResponse{Todos: todos.Trash(), Notes: notes.Trash()})
+}
+
+func emptyTrash(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	// Всё, что удалено до текущего момента
+	if err := purgeTrash(login, time.Now().Add(time.Second)); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func restoreTodo(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	restored, err := todos.Restore(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	searchIndex.Put(login, todoDoc(*restored))
+
+	writeJSON(w, http.StatusOK, restored)
+}
+
+func purgeTodo(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var todos todo.Todos
+	if err := todos.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Purge(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := todos.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := forgetRelations(login, []int{id}, nil); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func restoreNote(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	restored, err := notes.Restore(id)
+	if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	// Папку могли удалить, пока заметка лежала в корзине
+	if err := checkFolderExists(login, restored.FolderID); errors.Is(err, note.ErrFolderNotFound) {
+		restored.FolderID = note.RootFolderID
+	} else if err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	searchIndex.Put(login, noteDoc(*restored))
+
+	writeJSON(w, http.StatusOK, restored)
+}
+
+func purgeNote(w http.ResponseWriter, r *http.Request) {
+	login := r.Context().Value("user").(string)
+
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var notes note.Notes
+	if err := notes.Load(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := notes.Purge(id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	if err := notes.Save(login); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := note.CollectBlobs(login, notes); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := forgetRelations(login, nil, []int{id}); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+	if err := forgetRevisions(login, id); err != nil {
+		writeDomainError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,229 @@
+package tag
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sptodo/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const dataDir = "data"
+
+var (
+	ErrNotFound     = errors.New("тег не найден")
+	ErrExists       = errors.New("тег с таким названием уже есть")
+	ErrInvalidColor = errors.New("цвет должен быть в формате #rrggbb")
+	ErrInvalidMode  = errors.New("неизвестный режим фильтра по тегам")
+)
+
+// Значения для ?tag_mode=
+const (
+	ModeAny = "any"
+	ModeAll = "all"
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Цвет в формате #rrggbb, пустая строка — цвет не задан.
+// Тот же формат у списков и заметок, они проверяют цвет здесь же.
+func ValidColor(color string) bool {
+	return color == "" || colorPattern.MatchString(color)
+}
+
+// Один тег навешивается и на задачи, и на заметки — там хранится только его ID
+type Tag struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Patch struct {
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+}
+
+type Tags []Tag
+
+func (tags Tags) nextID() int {
+	maxID := 0
+	for _, t := range tags {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	return maxID + 1
+}
+
+// Названия сравниваются без учёта регистра: "Работа" и "работа" — один тег
+func (tags Tags) byName(name string) *Tag {
+	for i := range tags {
+		if strings.EqualFold(tags[i].Name, name) {
+			return &tags[i]
+		}
+	}
+	return nil
+}
+
+func (tags *Tags) Add(name, color string) (Tag, error) {
+	if !ValidColor(color) {
+		return Tag{}, ErrInvalidColor
+	}
+	if tags.byName(name) != nil {
+		return Tag{}, ErrExists
+	}
+
+	newTag := Tag{
+		ID:        tags.nextID(),
+		Name:      name,
+		Color:     color,
+		CreatedAt: time.Now(),
+	}
+
+	*tags = append(*tags, newTag)
+	return newTag, nil
+}
+
+func (tags Tags) Find(id int) (*Tag, error) {
+	for i := range tags {
+		if tags[i].ID == id {
+			return &tags[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (tags Tags) Update(id int, patch Patch) (*Tag, error) {
+	tag, err := tags.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if patch.Color != nil && !ValidColor(*patch.Color) {
+		return nil, ErrInvalidColor
+	}
+	if patch.Name != nil {
+		if other := tags.byName(*patch.Name); other != nil && other.ID != id {
+			return nil, ErrExists
+		}
+		tag.Name = *patch.Name
+	}
+	if patch.Color != nil {
+		tag.Color = *patch.Color
+	}
+	return tag, nil
+}
+
+// Ссылки на тег из задач и заметок чистит вызывающий
+func (tags *Tags) Delete(id int) error {
+	for i, t := range *tags {
+		if t.ID == id {
+			*tags = append((*tags)[:i], (*tags)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// Проверяет, что все ID существуют
+func (tags Tags) Check(ids []int) error {
+	for _, id := range ids {
+		if _, err := tags.Find(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Переводит значения ?tag= в ID. Сначала ищем по названию, иначе тег "2024"
+// нельзя было бы выбрать по имени; число, не совпавшее ни с одним названием, — ID.
+// Чтобы передать именно ID, есть ?tag_id=.
+func (tags Tags) Resolve(values []string) ([]int, error) {
+	ids := make([]int, 0, len(values))
+	for _, v := range values {
+		if tag := tags.byName(v); tag != nil {
+			ids = append(ids, tag.ID)
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+		if _, err := tags.Find(id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Подходит ли набор тегов элемента под фильтр. Пустой фильтр подходит всем.
+func Match(have, want []int, mode string) bool {
+	if len(want) == 0 {
+		return true
+	}
+	for _, id := range want {
+		found := slices.Contains(have, id)
+		if mode == ModeAll && !found {
+			return false
+		}
+		if mode != ModeAll && found {
+			return true
+		}
+	}
+	return mode == ModeAll
+}
+
+func ValidMode(mode string) bool {
+	return mode == "" || mode == ModeAny || mode == ModeAll
+}
+
+// Убирает повторы, чтобы в файле не копились одинаковые ID
+func Normalize(ids []int) []int {
+	if len(ids) == 0 {
+		return nil
+	}
+	result := slices.Clone(ids)
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+func (tags Tags) Save(login string) error {
+	defer metrics.StorageTimer("tags", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "tags.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(tags)
+}
+
+func (tags *Tags) Load(login string) error {
+	defer metrics.StorageTimer("tags", "read")()
+
+	path := filepath.Join(dataDir, login, "tags.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			*tags = Tags{}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(tags)
+}
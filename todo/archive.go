@@ -0,0 +1,57 @@
+package todo
+
+import "time"
+
+// Архивные задачи не попадают в обычные выдачи, но находятся по ID и редактируются как обычно
+func (todos Todos) Archived(archived bool) Todos {
+	result := Todos{}
+	for _, t := range todos {
+		if (t.ArchivedAt != nil) == archived {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func (todos Todos) Archive(id int) (*Todo, error) {
+	task, err := todos.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.ArchivedAt == nil {
+		now := time.Now()
+		task.ArchivedAt = &now
+		task.Version++
+	}
+	return task, nil
+}
+
+func (todos Todos) Unarchive(id int) (*Todo, error) {
+	task, err := todos.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.ArchivedAt != nil {
+		task.ArchivedAt = nil
+		task.Version++
+	}
+	return task, nil
+}
+
+// Архивирует задачи, выполненные раньше before; отдаёт ID заархивированных
+func (todos Todos) ArchiveCompletedBefore(before time.Time) []int {
+	var archived []int
+	now := time.Now()
+	for i := range todos {
+		t := &todos[i]
+		if t.DeletedAt != nil || t.ArchivedAt != nil || !t.Completed || t.CompletedAt == nil {
+			continue
+		}
+		if t.CompletedAt.Before(before) {
+			t.ArchivedAt = &now
+			t.Version++
+			archived = append(archived, t.ID)
+		}
+	}
+	return archived
+}
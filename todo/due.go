@@ -0,0 +1,130 @@
+package todo
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidDue      = errors.New("неверный формат срока")
+	ErrInvalidReminder = errors.New("неверный формат напоминания")
+	ErrInvalidTimezone = errors.New("неизвестный часовой пояс")
+	ErrInvalidFilter   = errors.New("неизвестный фильтр")
+)
+
+// Значения для ?due=
+const (
+	DueToday   = "today"
+	DueOverdue = "overdue"
+	DueWeek    = "week"
+)
+
+// Форматы, в которых принимаем срок. Без смещения время считается в зоне задачи
+var localLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// Загружает IANA-зону; пустая строка — локальное время сервера
+func LoadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// Разбирает срок: "2026-10-20" — на весь день, "2026-10-20T18:00" — время в зоне loc,
+// RFC3339 — точный момент со своим смещением
+func ParseDue(value string, loc *time.Location) (at time.Time, allDay bool, err error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, false, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(loc), false, nil
+	}
+	return time.Time{}, false, ErrInvalidDue
+}
+
+// Зона задачи; если сохранённая зона неизвестна — локальная
+func (t Todo) Location() *time.Location {
+	loc, err := LoadLocation(t.TZ)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// Момент, после которого задача считается просроченной
+func (t Todo) Deadline() (time.Time, bool) {
+	if t.DueAt == nil {
+		return time.Time{}, false
+	}
+	if t.DueAllDay {
+		due := t.DueAt.In(t.Location())
+		return time.Date(due.Year(), due.Month(), due.Day()+1, 0, 0, 0, 0, due.Location()), true
+	}
+	return *t.DueAt, true
+}
+
+func (t Todo) Overdue(now time.Time) bool {
+	deadline, ok := t.Deadline()
+	return ok && !t.Completed && now.After(deadline)
+}
+
+// Срок попадает в [from, to)
+func (t Todo) DueBetween(from, to time.Time) bool {
+	if t.DueAt == nil {
+		return false
+	}
+	if t.DueAllDay {
+		// Для срока-даты сравниваем календарные дни в зоне запроса
+		due := t.DueAt.In(t.Location())
+		day := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, from.Location())
+		return !day.Before(from) && day.Before(to)
+	}
+	return !t.DueAt.Before(from) && t.DueAt.Before(to)
+}
+
+// Задачи, у которых подошло время напоминания
+func (todos Todos) DueReminders(now time.Time) Todos {
+	result := Todos{}
+	for _, task := range todos {
+		if !task.Completed && task.RemindAt != nil && !task.RemindAt.After(now) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// Фильтр ?due=: today, overdue или week относительно now в зоне now.Location()
+func (todos Todos) FilterDue(kind string, now time.Time) (Todos, error) {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var match func(Todo) bool
+	switch kind {
+	case DueToday:
+		end := startOfDay.AddDate(0, 0, 1)
+		match = func(t Todo) bool { return t.DueBetween(startOfDay, end) }
+	case DueWeek:
+		end := startOfDay.AddDate(0, 0, 7)
+		match = func(t Todo) bool { return t.DueBetween(startOfDay, end) }
+	case DueOverdue:
+		match = func(t Todo) bool { return t.Overdue(now) }
+	default:
+		return nil, ErrInvalidFilter
+	}
+
+	result := Todos{}
+	for _, task := range todos {
+		if match(task) {
+			result = append(result, task)
+		}
+	}
+	return result, nil
+}
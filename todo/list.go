@@ -0,0 +1,188 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sptodo/metrics"
+	"sptodo/tag"
+	"time"
+)
+
+// Список "Входящие" есть у каждого пользователя, в него попадают задачи без списка
+const (
+	InboxID   = 1
+	InboxName = "Inbox"
+)
+
+var (
+	ErrListNotFound   = errors.New("список не найден")
+	ErrInboxProtected = errors.New("список Inbox нельзя удалить или архивировать")
+	ErrListArchived   = errors.New("список в архиве")
+)
+
+type List struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	Archived  bool      `json:"archived"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ListPatch struct {
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+	Archived *bool   `json:"archived"`
+}
+
+type Lists []List
+
+func (lists Lists) nextID() int {
+	maxID := 0
+	for _, l := range lists {
+		if l.ID > maxID {
+			maxID = l.ID
+		}
+	}
+	return maxID + 1
+}
+
+func (lists *Lists) Add(name, color string) (List, error) {
+	if !tag.ValidColor(color) {
+		return List{}, tag.ErrInvalidColor
+	}
+
+	newList := List{
+		ID:        lists.nextID(),
+		Name:      name,
+		Color:     color,
+		CreatedAt: time.Now(),
+	}
+
+	*lists = append(*lists, newList)
+	return newList, nil
+}
+
+func (lists Lists) Find(id int) (*List, error) {
+	for i := range lists {
+		if lists[i].ID == id {
+			return &lists[i], nil
+		}
+	}
+	return nil, ErrListNotFound
+}
+
+// В архивный список задачи не добавляются и не переносятся
+func (lists Lists) Writable(id int) error {
+	list, err := lists.Find(id)
+	if err != nil {
+		return err
+	}
+	if list.Archived {
+		return ErrListArchived
+	}
+	return nil
+}
+
+func (lists Lists) Update(id int, patch ListPatch) (*List, error) {
+	list, err := lists.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if patch.Color != nil && !tag.ValidColor(*patch.Color) {
+		return nil, tag.ErrInvalidColor
+	}
+	if patch.Archived != nil && *patch.Archived && id == InboxID {
+		return nil, ErrInboxProtected
+	}
+
+	if patch.Name != nil {
+		list.Name = *patch.Name
+	}
+	if patch.Color != nil {
+		list.Color = *patch.Color
+	}
+	if patch.Archived != nil {
+		list.Archived = *patch.Archived
+	}
+	return list, nil
+}
+
+// Задачи удалённого списка переносятся во Входящие — см. Todos.MoveList
+func (lists *Lists) Delete(id int) error {
+	if id == InboxID {
+		return ErrInboxProtected
+	}
+	for i, l := range *lists {
+		if l.ID == id {
+			*lists = append((*lists)[:i], (*lists)[i+1:]...)
+			return nil
+		}
+	}
+	return ErrListNotFound
+}
+
+// Переносит все задачи из одного списка в другой
+func (todos Todos) MoveList(from, to int) {
+	for i := range todos {
+		if todos[i].ListID == from {
+			todos[i].ListID = to
+			todos[i].Version++
+		}
+	}
+}
+
+// Задачи одного списка
+func (todos Todos) InList(listID int) Todos {
+	result := Todos{}
+	for _, task := range todos {
+		if task.ListID == listID {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+func (lists Lists) Save(login string) error {
+	defer metrics.StorageTimer("lists", "write")()
+
+	dir := filepath.Join(dataDir, login)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, "lists.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewEncoder(file).Encode(lists)
+}
+
+func (lists *Lists) Load(login string) error {
+	defer metrics.StorageTimer("lists", "read")()
+
+	path := filepath.Join(dataDir, login, "lists.json")
+	file, err := os.Open(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		*lists = Lists{}
+	} else {
+		defer file.Close()
+		if err := json.NewDecoder(file).Decode(lists); err != nil {
+			return err
+		}
+	}
+
+	// Входящие создаются при первом обращении — так мигрируют старые пользователи
+	if _, err := lists.Find(InboxID); err != nil {
+		inbox := List{ID: InboxID, Name: InboxName, CreatedAt: time.Now()}
+		*lists = append(Lists{inbox}, *lists...)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package todo
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidPriority = errors.New("неизвестный приоритет")
+	ErrInvalidOrder    = errors.New("соседние задачи переданы в неверном порядке")
+)
+
+const (
+	PriorityNone   = "none"
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Чем больше, тем важнее. Пустой приоритет — то же, что none
+var priorityWeight = map[string]int{
+	"":             0,
+	PriorityNone:   0,
+	PriorityLow:    1,
+	PriorityMedium: 2,
+	PriorityHigh:   3,
+	PriorityUrgent: 4,
+}
+
+func ValidPriority(p string) bool {
+	_, ok := priorityWeight[p]
+	return ok
+}
+
+// Значения для ?sort=
+const (
+	SortCreated  = "created"
+	SortDue      = "due"
+	SortPriority = "priority"
+	SortManual   = "manual"
+)
+
+func ValidSort(by string) bool {
+	switch by {
+	case "", SortCreated, SortDue, SortPriority, SortManual:
+		return true
+	}
+	return false
+}
+
+// Порядок тот же, что у страниц Query, — см. lessKey
+func (todos Todos) Sort(by string) error {
+	if !ValidSort(by) {
+		return ErrInvalidFilter
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return lessKey(keyOf(todos[i], by), keyOf(todos[j], by), by)
+	})
+	return nil
+}
+
+// Ранги — строки в base36, порядок задаётся сравнением строк. Новый ранг всегда
+// можно вставить между двумя соседними, поэтому перенос меняет только одну задачу.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+func rankDigit(s string, i int) int {
+	return strings.IndexByte(rankDigits, s[i])
+}
+
+// Ранг строго между a и b. Пустая a — начало списка, пустая b — конец.
+func RankBetween(a, b string) string {
+	if b != "" && a >= b {
+		return "" // вызывающий проверяет порядок заранее
+	}
+
+	var prefix []byte
+	for i := 0; ; i++ {
+		lo := 0
+		if i < len(a) {
+			lo = rankDigit(a, i)
+		}
+		hi := len(rankDigits)
+		if b != "" {
+			hi = 0
+			if i < len(b) {
+				hi = rankDigit(b, i)
+			}
+		}
+
+		if lo == hi {
+			prefix = append(prefix, rankDigits[lo])
+			continue
+		}
+		if hi-lo > 1 {
+			return string(append(prefix, rankDigits[(lo+hi)/2]))
+		}
+
+		// Цифры соседние: берём цифру a и ищем место после остатка a
+		prefix = append(prefix, rankDigits[lo])
+		rest := ""
+		if i+1 < len(a) {
+			rest = a[i+1:]
+		}
+		return string(prefix) + RankBetween(rest, "")
+	}
+}
+
+// Ранг после a. При добавлении в конец увеличиваем первую цифру, которую можно,
+// чтобы ранги не удлинялись с каждой новой задачей.
+func RankAfter(a string) string {
+	for i := 0; i < len(a); i++ {
+		if d := rankDigit(a, i); d < len(rankDigits)-1 {
+			return a[:i] + string(rankDigits[d+1])
+		}
+	}
+	return a + string(rankDigits[len(rankDigits)/2])
+}
+
+func (todos Todos) lastRank() string {
+	last := ""
+	for _, t := range todos {
+		if t.Rank > last {
+			last = t.Rank
+		}
+	}
+	return last
+}
+
+// Раздаёт ранги задачам, созданным до появления ручной сортировки
+func (todos Todos) ensureRanks() {
+	last := todos.lastRank()
+	for i := range todos {
+		if todos[i].Rank == "" {
+			last = RankAfter(last)
+			todos[i].Rank = last
+		}
+	}
+}
+
+// Ставит задачу между prevID и nextID (0 — край списка)
+func (todos Todos) Move(id, prevID, nextID int) (*Todo, error) {
+	task, err := todos.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	prevRank, nextRank := "", ""
+	if prevID != 0 {
+		prev, err := todos.Find(prevID)
+		if err != nil {
+			return nil, err
+		}
+		prevRank = prev.Rank
+	}
+	if nextID != 0 {
+		next, err := todos.Find(nextID)
+		if err != nil {
+			return nil, err
+		}
+		nextRank = next.Rank
+	}
+
+	if nextRank != "" && prevRank >= nextRank {
+		return nil, ErrInvalidOrder
+	}
+
+	task.Version++
+	if nextID == 0 {
+		// Без правого соседа — в самый конец, чтобы не совпасть с чужим рангом
+		task.Rank = RankAfter(todos.lastRank())
+	} else {
+		task.Rank = RankBetween(prevRank, nextRank)
+	}
+	return task, nil
+}
@@ -0,0 +1,211 @@
+package todo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("неверный курсор страницы")
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+// Выборка задач для GET /api/todos. Нулевые поля не фильтруют.
+// Диапазоны дат полуоткрытые: [From, To).
+type Query struct {
+	Completed *bool
+	Text      string // подстрока в названии задачи или подзадач, без учёта регистра
+
+	CreatedFrom, CreatedTo     *time.Time
+	CompletedFrom, CompletedTo *time.Time
+
+	Sort   string // см. Sort*
+	Limit  int    // 0 — DefaultLimit
+	Cursor string // next_cursor предыдущей страницы
+}
+
+type Page struct {
+	Items      Todos  `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"` // пусто — страница последняя
+}
+
+// Ключ сортировки задачи. Курсор хранит ключ последней отданной задачи, поэтому
+// следующая страница не съезжает, даже если между запросами задачи добавили или удалили.
+type sortKey struct {
+	Sort     string     `json:"s"`
+	ID       int        `json:"id"`
+	Created  time.Time  `json:"c"`
+	Deadline *time.Time `json:"d,omitempty"`
+	Weight   int        `json:"w,omitempty"`
+	Rank     string     `json:"r,omitempty"`
+}
+
+func keyOf(t Todo, by string) sortKey {
+	key := sortKey{Sort: by, ID: t.ID, Created: t.CreatedAt, Weight: priorityWeight[t.Priority], Rank: t.Rank}
+	if deadline, ok := t.Deadline(); ok {
+		key.Deadline = &deadline
+	}
+	return key
+}
+
+// Сначала ближайшие сроки, без срока — в конце
+func lessDeadline(a, b sortKey) (less, equal bool) {
+	switch {
+	case a.Deadline == nil && b.Deadline == nil:
+		return false, true
+	case a.Deadline == nil || b.Deadline == nil:
+		return a.Deadline != nil, false
+	case a.Deadline.Equal(*b.Deadline):
+		return false, true
+	}
+	return a.Deadline.Before(*b.Deadline), false
+}
+
+// Полный порядок: при равенстве по выбранному полю решает ID
+func lessKey(a, b sortKey, by string) bool {
+	switch by {
+	case SortDue:
+		if less, equal := lessDeadline(a, b); !equal {
+			return less
+		}
+	case SortPriority:
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if less, equal := lessDeadline(a, b); !equal {
+			return less
+		}
+	case SortManual:
+		if a.Rank != b.Rank {
+			return a.Rank < b.Rank
+		}
+	default:
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created)
+		}
+	}
+	return a.ID < b.ID
+}
+
+func encodeCursor(key sortKey) string {
+	data, _ := json.Marshal(key)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor, by string) (sortKey, error) {
+	var key sortKey
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || json.Unmarshal(data, &key) != nil {
+		return key, ErrInvalidCursor
+	}
+	// Курсор от другой сортировки указывает непонятно куда
+	if key.Sort != by {
+		return key, ErrInvalidCursor
+	}
+	return key, nil
+}
+
+func (q Query) match(t Todo) bool {
+	if q.Text != "" && !containsText(t, strings.ToLower(q.Text)) {
+		return false
+	}
+	if !inRange(&t.CreatedAt, q.CreatedFrom, q.CreatedTo) {
+		return false
+	}
+	if (q.CompletedFrom != nil || q.CompletedTo != nil) && !inRange(t.CompletedAt, q.CompletedFrom, q.CompletedTo) {
+		return false
+	}
+	return true
+}
+
+func containsText(t Todo, text string) bool {
+	if strings.Contains(strings.ToLower(t.Title), text) {
+		return true
+	}
+	var walk func(subtasks []Subtask) bool
+	walk = func(subtasks []Subtask) bool {
+		for _, s := range subtasks {
+			if strings.Contains(strings.ToLower(s.Title), text) || walk(s.Subtasks) {
+				return true
+			}
+		}
+		return false
+	}
+	return walk(t.Subtasks)
+}
+
+func inRange(at, from, to *time.Time) bool {
+	if at == nil {
+		return false
+	}
+	if from != nil && at.Before(*from) {
+		return false
+	}
+	if to != nil && !at.Before(*to) {
+		return false
+	}
+	return true
+}
+
+// Фильтрует и сортирует без разбиения на страницы. Limit и Cursor не учитываются.
+func (q Query) Items(todos Todos) (Todos, error) {
+	if !ValidSort(q.Sort) {
+		return nil, ErrInvalidFilter
+	}
+
+	items := Todos{}
+	for _, t := range todos.Filter(q.Completed) {
+		if q.match(t) {
+			items = append(items, t)
+		}
+	}
+	items.Sort(q.Sort)
+	return items, nil
+}
+
+// Фильтрует, сортирует и отрезает одну страницу. Исходный срез не меняется.
+func (q Query) Apply(todos Todos) (Page, error) {
+	if q.Limit < 0 || q.Limit > MaxLimit {
+		return Page{}, ErrInvalidFilter
+	}
+	if !ValidSort(q.Sort) {
+		return Page{}, ErrInvalidFilter
+	}
+	limit := q.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	var after *sortKey
+	if q.Cursor != "" {
+		key, err := decodeCursor(q.Cursor, q.Sort)
+		if err != nil {
+			return Page{}, err
+		}
+		after = &key
+	}
+
+	all, err := q.Items(todos)
+	if err != nil {
+		return Page{}, err
+	}
+	items := Todos{}
+	for _, t := range all {
+		if after == nil || lessKey(*after, keyOf(t, q.Sort), q.Sort) {
+			items = append(items, t)
+		}
+	}
+
+	page := Page{Items: items}
+	if len(items) > limit {
+		page.Items = slices.Clip(items[:limit])
+		page.NextCursor = encodeCursor(keyOf(items[limit-1], q.Sort))
+	}
+	return page, nil
+}
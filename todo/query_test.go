@@ -0,0 +1,216 @@
+package todo
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2026, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+func day(n int) *time.Time {
+	t := base.AddDate(0, 0, n)
+	return &t
+}
+
+// Пять задач с разными сроками, приоритетами и рангами
+func queryFixture() Todos {
+	return Todos{
+		{ID: 1, Title: "Купить молоко", CreatedAt: *day(0), Priority: PriorityLow, Rank: "c", DueAt: day(5)},
+		{ID: 2, Title: "Отчёт", CreatedAt: *day(1), Priority: PriorityUrgent, Rank: "a", Completed: true, CompletedAt: day(2)},
+		{ID: 3, Title: "Позвонить", CreatedAt: *day(2), Rank: "e", DueAt: day(3),
+			Subtasks: []Subtask{{ID: 1, Title: "найти номер МОЛОКОзавода"}}},
+		{ID: 4, Title: "Спорт", CreatedAt: *day(3), Priority: PriorityUrgent, Rank: "b", DueAt: day(1)},
+		{ID: 5, Title: "Прочитать книгу", CreatedAt: *day(3), Priority: PriorityMedium, Rank: "d", Completed: true, CompletedAt: day(6)},
+	}
+}
+
+func ids(todos Todos) []int {
+	result := []int{}
+	for _, t := range todos {
+		result = append(result, t.ID)
+	}
+	return result
+}
+
+func TestQueryFilters(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name string
+		q    Query
+		want []int
+	}{
+		{"без фильтров", Query{}, []int{1, 2, 3, 4, 5}},
+		{"выполненные", Query{Completed: &yes}, []int{2, 5}},
+		{"невыполненные", Query{Completed: &no}, []int{1, 3, 4}},
+		{"текст без учёта регистра и в подзадачах", Query{Text: "молоко"}, []int{1, 3}},
+		{"создано в диапазоне [from, to)", Query{CreatedFrom: day(1), CreatedTo: day(3)}, []int{2, 3}},
+		{"выполнено с", Query{CompletedFrom: day(3)}, []int{5}},
+		{"фильтры складываются", Query{Completed: &no, Text: "о", CreatedFrom: day(1)}, []int{3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := tt.q.Apply(queryFixture())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(page.Items); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuerySort(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []int
+	}{
+		{"", []int{1, 2, 3, 4, 5}},
+		{SortCreated, []int{1, 2, 3, 4, 5}}, // 4 и 5 созданы одновременно — решает ID
+		{SortDue, []int{4, 3, 1, 2, 5}},     // без срока — в конце
+		{SortPriority, []int{4, 2, 5, 1, 3}},
+		{SortManual, []int{2, 4, 1, 5, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			items, err := Query{Sort: tt.sort}.Items(queryFixture())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(items); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (Query{Sort: "title"}).Items(queryFixture()); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("неизвестная сортировка: err = %v", err)
+	}
+}
+
+// Страницы по курсору дают все задачи ровно по разу и в том же порядке
+func TestQueryCursorRoundTrip(t *testing.T) {
+	for _, by := range []string{SortCreated, SortDue, SortPriority, SortManual} {
+		t.Run(by, func(t *testing.T) {
+			todos := queryFixture()
+			all, err := Query{Sort: by}.Items(todos)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			q := Query{Sort: by, Limit: 2}
+			for pages := 0; ; pages++ {
+				if pages > len(todos) {
+					t.Fatal("курсор не продвигается")
+				}
+				page, err := q.Apply(todos)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, ids(page.Items)...)
+				if page.NextCursor == "" {
+					break
+				}
+				q.Cursor = page.NextCursor
+			}
+
+			if want := ids(all); !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestQueryCursorSurvivesChanges(t *testing.T) {
+	todos := queryFixture()
+	first, err := Query{Limit: 2}.Apply(todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Между страницами удалили уже отданную задачу и добавили новую в конец
+	todos = slices.DeleteFunc(todos, func(t Todo) bool { return t.ID == 1 })
+	todos = append(todos, Todo{ID: 6, CreatedAt: *day(10)})
+
+	second, err := Query{Limit: 10, Cursor: first.NextCursor}.Apply(todos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(second.Items), []int{3, 4, 5, 6}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestQueryInvalidCursor(t *testing.T) {
+	page, err := Query{Sort: SortDue, Limit: 1}.Apply(queryFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+	}{
+		{"мусор", Query{Cursor: "не base64!"}},
+		{"base64, но не JSON", Query{Cursor: "bm90IGpzb24"}},
+		{"курсор другой сортировки", Query{Sort: SortPriority, Cursor: page.NextCursor}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.q.Apply(queryFixture()); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestQueryLimit(t *testing.T) {
+	many := Todos{}
+	for i := 1; i <= MaxLimit+10; i++ {
+		many = append(many, Todo{ID: i, CreatedAt: base})
+	}
+
+	tests := []struct {
+		limit    int
+		wantLen  int
+		wantNext bool
+		wantErr  bool
+	}{
+		{limit: -1, wantErr: true},
+		{limit: 0, wantLen: DefaultLimit, wantNext: true},
+		{limit: 1, wantLen: 1, wantNext: true},
+		{limit: MaxLimit, wantLen: MaxLimit, wantNext: true},
+		{limit: MaxLimit + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		page, err := Query{Limit: tt.limit}.Apply(many)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("limit %d: err = %v, want ErrInvalidFilter", tt.limit, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("limit %d: %v", tt.limit, err)
+		}
+		if len(page.Items) != tt.wantLen || (page.NextCursor != "") != tt.wantNext {
+			t.Errorf("limit %d: %d задач, next_cursor %q", tt.limit, len(page.Items), page.NextCursor)
+		}
+	}
+
+	// Последняя страница ровно по размеру — без курсора
+	page, err := Query{Limit: 5}.Apply(queryFixture())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 5 || page.NextCursor != "" {
+		t.Errorf("%d задач, next_cursor %q", len(page.Items), page.NextCursor)
+	}
+}
@@ -0,0 +1,195 @@
+package todo
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+var ErrInvalidRecurrence = errors.New("неверное правило повторения")
+
+// Виды повторения
+const (
+	FreqNone            = "none"
+	FreqDaily           = "daily"
+	FreqWeekly          = "weekly"
+	FreqMonthly         = "monthly"
+	FreqAfterCompletion = "after_completion" // через N дней после выполнения
+)
+
+// Правило повторения в духе RRULE, но только то, что нам нужно
+type Recurrence struct {
+	Freq     string         `json:"freq"`
+	Interval int            `json:"interval,omitempty"`  // каждые N дней/недель/месяцев, 0 — то же, что 1
+	Weekdays []time.Weekday `json:"weekdays,omitempty"`  // для weekly: 0 — воскресенье ... 6 — суббота
+	MonthDay int            `json:"month_day,omitempty"` // для monthly; если в месяце меньше дней — последний день
+	// Для monthly без MonthDay: число из срока, с которым правило задали. Без него
+	// задача от 31 января ушла бы на 28 февраля, а дальше на 28-е каждого месяца.
+	AnchorDay int `json:"anchor_day,omitempty"`
+}
+
+func (rec Recurrence) Validate() error {
+	switch rec.Freq {
+	case FreqDaily, FreqWeekly, FreqMonthly, FreqAfterCompletion:
+	default:
+		return ErrInvalidRecurrence
+	}
+	if rec.Interval < 0 || rec.MonthDay < 0 || rec.MonthDay > 31 || rec.AnchorDay < 0 || rec.AnchorDay > 31 {
+		return ErrInvalidRecurrence
+	}
+	for _, day := range rec.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return ErrInvalidRecurrence
+		}
+	}
+	return nil
+}
+
+func (rec Recurrence) interval() int {
+	if rec.Interval < 1 {
+		return 1
+	}
+	return rec.Interval
+}
+
+// Следующее наступление после from. Считаем в календарных днях зоны loc и сохраняем
+// время на часах, поэтому задача «в 9:00» остаётся в 9:00 и после перевода часов.
+// Для after_completion from — момент выполнения.
+func (rec Recurrence) Next(from time.Time, loc *time.Location) time.Time {
+	from = from.In(loc)
+	n := rec.interval()
+
+	switch rec.Freq {
+	case FreqWeekly:
+		return rec.nextWeekly(from, n)
+	case FreqMonthly:
+		day := rec.MonthDay
+		if day == 0 {
+			day = rec.AnchorDay
+		}
+		if day == 0 {
+			day = from.Day()
+		}
+		// Первое число нужного месяца, потом ограничиваем день его длиной
+		first := time.Date(from.Year(), from.Month()+time.Month(n), 1, 0, 0, 0, 0, loc)
+		day = min(day, daysIn(first))
+		return time.Date(first.Year(), first.Month(), day, from.Hour(), from.Minute(), from.Second(), 0, loc)
+	default: // daily, after_completion
+		return addDays(from, n)
+	}
+}
+
+func (rec Recurrence) nextWeekly(from time.Time, n int) time.Time {
+	days := rec.Weekdays
+	if len(days) == 0 {
+		days = []time.Weekday{from.Weekday()}
+	}
+
+	// Неделя начинается с понедельника; подходят только недели с шагом n от недели from
+	weekStart := func(t time.Time) time.Time {
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+	}
+	base := weekStart(from)
+
+	for d := 1; d <= 7*n+7; d++ {
+		candidate := addDays(from, d)
+		weeks := int(weekStart(candidate).Sub(base).Hours()+12) / (24 * 7)
+		if weeks%n != 0 {
+			continue
+		}
+		for _, day := range days {
+			if candidate.Weekday() == day {
+				return candidate
+			}
+		}
+	}
+	return addDays(from, 7*n) // сюда не попадаем при корректных днях недели
+}
+
+// Копия правила с числом месяца из срока due — см. AnchorDay
+func (rec Recurrence) anchored(due *time.Time, loc *time.Location) *Recurrence {
+	rec.AnchorDay = 0
+	if rec.Freq == FreqMonthly && rec.MonthDay == 0 && due != nil {
+		rec.AnchorDay = due.In(loc).Day()
+	}
+	return &rec
+}
+
+// Копия дерева подзадач со снятыми отметками — для следующего повторения
+func resetSubtasks(subtasks []Subtask) []Subtask {
+	if subtasks == nil {
+		return nil
+	}
+	result := make([]Subtask, len(subtasks))
+	for i, s := range subtasks {
+		result[i] = Subtask{ID: s.ID, Title: s.Title, Subtasks: resetSubtasks(s.Subtasks)}
+	}
+	return result
+}
+
+func addDays(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+// Создаёт следующее повторение выполненной задачи. Срок переносится по правилу
+// (пропуская уже прошедшие даты), напоминание сдвигается вместе со сроком.
+func (todos *Todos) spawnNext(task Todo, completedAt time.Time) Todo {
+	loc := task.Location()
+	rec := *task.Recurrence
+	// Правила, заданные до AnchorDay, привязываем к первому сроку, который видим
+	if rec.Freq == FreqMonthly && rec.MonthDay == 0 && rec.AnchorDay == 0 {
+		rec = *rec.anchored(task.DueAt, loc)
+	}
+
+	next := Todo{
+		ID:         todos.nextID(),
+		Version:    1,
+		ListID:     task.ListID,
+		Title:      task.Title,
+		CreatedAt:  completedAt,
+		DueAllDay:  task.DueAllDay,
+		TZ:         task.TZ,
+		Recurrence: &rec,
+		History:    append(append([]time.Time(nil), task.History...), completedAt),
+		Priority:   task.Priority,
+		Rank:       RankAfter(todos.lastRank()),
+		Subtasks:   resetSubtasks(task.Subtasks),
+		Tags:       slices.Clone(task.Tags),
+
+		AutoComplete: task.AutoComplete,
+	}
+
+	var due time.Time
+	switch {
+	case rec.Freq == FreqAfterCompletion && task.DueAt != nil:
+		// Дата от выполнения, время на часах — от прежнего срока
+		old := task.DueAt.In(loc)
+		done := completedAt.In(loc)
+		due = rec.Next(time.Date(done.Year(), done.Month(), done.Day(), old.Hour(), old.Minute(), old.Second(), 0, loc), loc)
+	case rec.Freq == FreqAfterCompletion || task.DueAt == nil:
+		due = rec.Next(completedAt, loc)
+	default:
+		due = rec.Next(*task.DueAt, loc)
+		for {
+			next.DueAt = &due
+			if deadline, _ := next.Deadline(); deadline.After(completedAt) {
+				break
+			}
+			due = rec.Next(due, loc)
+		}
+	}
+	next.DueAt = &due
+
+	if task.RemindAt != nil && task.DueAt != nil {
+		remind := due.Add(task.RemindAt.Sub(*task.DueAt))
+		next.RemindAt = &remind
+	}
+
+	*todos = append(*todos, next)
+	return next
+}
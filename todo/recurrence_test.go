@@ -0,0 +1,108 @@
+package todo
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // зоны нужны и там, где в системе нет tzdata
+)
+
+// В 2026 году Берлин переводит часы 29 марта (02:00 → 03:00) и 25 октября (03:00 → 02:00)
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestRecurrenceNextAcrossDST(t *testing.T) {
+	loc := berlin(t)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		rec  Recurrence
+		from time.Time
+		want time.Time
+	}{
+		{"daily spring forward", Recurrence{Freq: FreqDaily}, at(time.March, 28, 9, 0), at(time.March, 29, 9, 0)},
+		{"daily fall back", Recurrence{Freq: FreqDaily}, at(time.October, 24, 9, 0), at(time.October, 25, 9, 0)},
+		{"daily interval over spring", Recurrence{Freq: FreqDaily, Interval: 2}, at(time.March, 28, 23, 30), at(time.March, 30, 23, 30)},
+		{"daily late evening fall back", Recurrence{Freq: FreqDaily}, at(time.October, 25, 23, 0), at(time.October, 26, 23, 0)},
+		{"weekly spring forward", Recurrence{Freq: FreqWeekly}, at(time.March, 25, 9, 0), at(time.April, 1, 9, 0)},
+		{"weekly fall back", Recurrence{Freq: FreqWeekly}, at(time.October, 21, 9, 0), at(time.October, 28, 9, 0)},
+		{"weekly weekdays over fall back", Recurrence{Freq: FreqWeekly, Weekdays: []time.Weekday{time.Monday, time.Friday}}, at(time.October, 24, 9, 0), at(time.October, 26, 9, 0)},
+		{"weekly every second week", Recurrence{Freq: FreqWeekly, Interval: 2, Weekdays: []time.Weekday{time.Monday}}, at(time.March, 23, 9, 0), at(time.April, 6, 9, 0)},
+		{"monthly spring forward", Recurrence{Freq: FreqMonthly}, at(time.March, 15, 9, 0), at(time.April, 15, 9, 0)},
+		{"monthly fall back", Recurrence{Freq: FreqMonthly}, at(time.October, 15, 9, 0), at(time.November, 15, 9, 0)},
+		{"monthly day clamped", Recurrence{Freq: FreqMonthly, MonthDay: 31}, at(time.March, 31, 9, 0), at(time.April, 30, 9, 0)},
+		{"monthly anchor restored", Recurrence{Freq: FreqMonthly, AnchorDay: 31}, at(time.February, 28, 9, 0), at(time.March, 31, 9, 0)},
+		{"after completion spring forward", Recurrence{Freq: FreqAfterCompletion, Interval: 3}, at(time.March, 27, 18, 30), at(time.March, 30, 18, 30)},
+		{"after completion fall back", Recurrence{Freq: FreqAfterCompletion}, at(time.October, 24, 18, 30), at(time.October, 25, 18, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// from передаём в UTC: Next сам переводит его в зону правила
+			got := tt.rec.Next(tt.from.UTC(), loc)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.from, got.In(loc), tt.want)
+			}
+		})
+	}
+}
+
+// Цепочка от 31 января не должна застрять на 28-м после февраля
+func TestMonthlyKeepsAnchorDay(t *testing.T) {
+	todos := Todos{}
+	task := todos.Add("отчёт")
+	due, tz := "2030-01-31T09:00", "Europe/Berlin"
+	if _, err := todos.Update(task.ID, Patch{Due: &due, TZ: &tz, Recurrence: &Recurrence{Freq: FreqMonthly}}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"2030-02-28", "2030-03-31", "2030-04-30", "2030-05-31"}
+	id := task.ID
+	for _, day := range want {
+		next, err := todos.Complete(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next == nil {
+			t.Fatal("повторение не создано")
+		}
+		if got := next.DueAt.In(next.Location()).Format("2006-01-02"); got != day {
+			t.Errorf("срок %s, want %s", got, day)
+		}
+		id = next.ID
+	}
+}
+
+func TestCompleteSpawnsNextOnce(t *testing.T) {
+	todos := Todos{}
+	task := todos.Add("зарядка")
+	if _, err := todos.Update(task.ID, Patch{Recurrence: &Recurrence{Freq: FreqDaily}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := todos.Complete(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := todos.Reopen(task.ID); err != nil {
+		t.Fatal(err)
+	}
+	next, err := todos.Complete(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next != nil {
+		t.Errorf("повторное выполнение создало ещё одно повторение #%d", next.ID)
+	}
+	if len(todos) != 2 {
+		t.Errorf("задач %d, want 2", len(todos))
+	}
+}
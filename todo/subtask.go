@@ -0,0 +1,297 @@
+package todo
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Глубина вложенности: подзадача -> её подзадача -> ещё одна
+const MaxSubtaskDepth = 3
+
+var (
+	ErrSubtaskNotFound = errors.New("подзадача не найдена")
+	ErrSubtaskTooDeep  = errors.New("слишком глубокая вложенность подзадач")
+)
+
+type Subtask struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	Subtasks    []Subtask  `json:"subtasks,omitempty"`
+}
+
+type SubtaskPatch struct {
+	Title     *string `json:"title"`
+	Completed *bool   `json:"completed"`
+}
+
+// Прогресс по непосредственным подзадачам: "3/5"
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+func progressOf(subtasks []Subtask) *Progress {
+	if len(subtasks) == 0 {
+		return nil
+	}
+	p := &Progress{Total: len(subtasks)}
+	for _, s := range subtasks {
+		if s.Completed {
+			p.Done++
+		}
+	}
+	return p
+}
+
+// Прогресс считается на лету и отдаётся клиенту. В файл он не пишется — см. storedTodo.
+func (t Todo) MarshalJSON() ([]byte, error) {
+	type plain Todo
+	return json.Marshal(struct {
+		plain
+		Progress *Progress `json:"progress,omitempty"`
+	}{plain(t), progressOf(t.Subtasks)})
+}
+
+func (s Subtask) MarshalJSON() ([]byte, error) {
+	type plain Subtask
+	return json.Marshal(struct {
+		plain
+		Progress *Progress `json:"progress,omitempty"`
+	}{plain(s), progressOf(s.Subtasks)})
+}
+
+// Вид задачи в todos.json: те же поля, но подзадачи без MarshalJSON, а значит и без прогресса.
+// Subtasks снаружи перекрывает одноимённое поле встроенной задачи.
+type storedTodo struct {
+	plainTodo
+	Subtasks []storedSubtask `json:"subtasks,omitempty"`
+}
+
+type plainTodo Todo
+
+type storedSubtask struct {
+	ID          int             `json:"id"`
+	Title       string          `json:"title"`
+	Completed   bool            `json:"completed"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+	Subtasks    []storedSubtask `json:"subtasks,omitempty"`
+}
+
+func storedSubtasks(subtasks []Subtask) []storedSubtask {
+	if subtasks == nil {
+		return nil
+	}
+	result := make([]storedSubtask, len(subtasks))
+	for i, s := range subtasks {
+		result[i] = storedSubtask{s.ID, s.Title, s.Completed, s.CompletedAt, storedSubtasks(s.Subtasks)}
+	}
+	return result
+}
+
+func (todos Todos) stored() []storedTodo {
+	result := make([]storedTodo, len(todos))
+	for i, t := range todos {
+		result[i] = storedTodo{plainTodo(t), storedSubtasks(t.Subtasks)}
+	}
+	return result
+}
+
+func maxSubtaskID(subtasks []Subtask) int {
+	maxID := 0
+	for _, s := range subtasks {
+		maxID = max(maxID, s.ID, maxSubtaskID(s.Subtasks))
+	}
+	return maxID
+}
+
+// Ищет подзадачу в дереве; depth — уровень найденной (1 — прямо под задачей)
+func findSubtask(subtasks []Subtask, id, depth int) (*Subtask, int) {
+	for i := range subtasks {
+		if subtasks[i].ID == id {
+			return &subtasks[i], depth
+		}
+		if found, d := findSubtask(subtasks[i].Subtasks, id, depth+1); found != nil {
+			return found, d
+		}
+	}
+	return nil, 0
+}
+
+// Цепочка от подзадачи верхнего уровня до подзадачи id включительно; nil — такой нет
+func subtaskPath(subtasks []Subtask, id int) []*Subtask {
+	for i := range subtasks {
+		if subtasks[i].ID == id {
+			return []*Subtask{&subtasks[i]}
+		}
+		if rest := subtaskPath(subtasks[i].Subtasks, id); rest != nil {
+			return append([]*Subtask{&subtasks[i]}, rest...)
+		}
+	}
+	return nil
+}
+
+// Родитель подзадачи id; 0 — она верхнего уровня
+func subtaskParent(subtasks []Subtask, id int) int {
+	path := subtaskPath(subtasks, id)
+	if len(path) < 2 {
+		return 0
+	}
+	return path[len(path)-2].ID
+}
+
+func removeSubtask(subtasks *[]Subtask, id int) bool {
+	for i := range *subtasks {
+		if (*subtasks)[i].ID == id {
+			*subtasks = append((*subtasks)[:i], (*subtasks)[i+1:]...)
+			return true
+		}
+		if removeSubtask(&(*subtasks)[i].Subtasks, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func (todos Todos) Subtask(todoID, subtaskID int) (*Subtask, error) {
+	task, err := todos.Find(todoID)
+	if err != nil {
+		return nil, err
+	}
+	sub, _ := findSubtask(task.Subtasks, subtaskID, 1)
+	if sub == nil {
+		return nil, ErrSubtaskNotFound
+	}
+	return sub, nil
+}
+
+// parentID 0 — подзадача верхнего уровня
+func (todos *Todos) AddSubtask(todoID, parentID int, title string) (*Subtask, error) {
+	task, err := todos.Find(todoID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &task.Subtasks
+	if parentID != 0 {
+		parent, depth := findSubtask(task.Subtasks, parentID, 1)
+		if parent == nil {
+			return nil, ErrSubtaskNotFound
+		}
+		if depth >= MaxSubtaskDepth {
+			return nil, ErrSubtaskTooDeep
+		}
+		list = &parent.Subtasks
+	}
+
+	id := maxSubtaskID(task.Subtasks) + 1
+	*list = append(*list, Subtask{ID: id, Title: title})
+	task.Version++
+
+	// Новая невыполненная подзадача снимает автоматическую отметку с родителей
+	if err := todos.syncAutoComplete(todoID, parentID); err != nil {
+		return nil, err
+	}
+	return todos.Subtask(todoID, id)
+}
+
+func (todos *Todos) UpdateSubtask(todoID, subtaskID int, patch SubtaskPatch) (*Subtask, error) {
+	sub, err := todos.Subtask(todoID, subtaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if patch.Title != nil {
+		sub.Title = *patch.Title
+	}
+	changed := patch.Completed != nil && *patch.Completed != sub.Completed
+	if changed {
+		setSubtaskCompleted(sub, *patch.Completed, time.Now())
+	}
+	// Подзадача — часть задачи, меняется и версия задачи
+	task, _ := todos.Find(todoID)
+	task.Version++
+
+	if changed {
+		if err := todos.syncAutoComplete(todoID, subtaskParent(task.Subtasks, subtaskID)); err != nil {
+			return nil, err
+		}
+	}
+	return todos.Subtask(todoID, subtaskID)
+}
+
+func (todos *Todos) DeleteSubtask(todoID, subtaskID int) error {
+	task, err := todos.Find(todoID)
+	if err != nil {
+		return err
+	}
+	parentID := subtaskParent(task.Subtasks, subtaskID)
+	if !removeSubtask(&task.Subtasks, subtaskID) {
+		return ErrSubtaskNotFound
+	}
+	task.Version++
+	return todos.syncAutoComplete(todoID, parentID)
+}
+
+func setSubtaskCompleted(sub *Subtask, completed bool, now time.Time) {
+	sub.Completed = completed
+	sub.CompletedAt = nil
+	if completed {
+		sub.CompletedAt = &now
+	}
+}
+
+// С AutoComplete отметка родителя следует за детьми. У подзадачи parentID (0 — у самой
+// задачи) изменились дети: пересчитываем её и идём вверх, пока отметки меняются.
+// Родителей, чьи дети не менялись, не трогаем — отмеченное вручную так и остаётся.
+func (todos *Todos) syncAutoComplete(todoID, parentID int) error {
+	task, err := todos.Find(todoID)
+	if err != nil {
+		return err
+	}
+	if !task.AutoComplete {
+		return nil
+	}
+
+	var path []*Subtask
+	if parentID != 0 {
+		if path = subtaskPath(task.Subtasks, parentID); path == nil {
+			return ErrSubtaskNotFound
+		}
+	}
+
+	now := time.Now()
+	for i := len(path) - 1; i >= 0; i-- {
+		sub := path[i]
+		if len(sub.Subtasks) == 0 {
+			return nil
+		}
+		done := allCompleted(sub.Subtasks)
+		if done == sub.Completed {
+			return nil
+		}
+		setSubtaskCompleted(sub, done, now)
+	}
+
+	if len(task.Subtasks) == 0 {
+		return nil
+	}
+	switch done := allCompleted(task.Subtasks); {
+	case done && !task.Completed:
+		_, err = todos.Complete(todoID)
+	case !done && task.Completed:
+		err = todos.Reopen(todoID)
+	}
+	return err
+}
+
+func allCompleted(subtasks []Subtask) bool {
+	for _, s := range subtasks {
+		if !s.Completed {
+			return false
+		}
+	}
+	return true
+}
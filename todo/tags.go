@@ -0,0 +1,31 @@
+package todo
+
+import (
+	"slices"
+	"sptodo/tag"
+)
+
+// Задачи с тегами want; mode — tag.ModeAny или tag.ModeAll
+func (todos Todos) WithTags(want []int, mode string) Todos {
+	result := Todos{}
+	for _, task := range todos {
+		if tag.Match(task.Tags, want, mode) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// Снимает удалённый тег со всех задач
+func (todos Todos) RemoveTag(id int) {
+	for i := range todos {
+		if !slices.Contains(todos[i].Tags, id) {
+			continue
+		}
+		todos[i].Tags = slices.DeleteFunc(todos[i].Tags, func(t int) bool { return t == id })
+		if len(todos[i].Tags) == 0 {
+			todos[i].Tags = nil
+		}
+		todos[i].Version++
+	}
+}
@@ -6,30 +6,107 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sptodo/metrics"
+	"sptodo/seq"
+	"sptodo/tag"
 	"time"
 )
 
 const dataDir = "data"
 
+var ErrNotFound = errors.New("задача не найдена")
+
 // Моя одна задача
 type Todo struct {
-	Title       string     `json:"title"`
-	Completed   bool       `json:"completed"`
-	CreatedAt   time.Time  `json:"created_at"` //Берем не указатель, потому что нам не нужно менять значение, мы просто в моменте его скопировали и присвоили, все
-	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ID          int         `json:"id"`
+	Version     int         `json:"version"` // растёт при каждом изменении, отдаётся как ETag
+	ListID      int         `json:"list_id"`
+	Title       string      `json:"title"`
+	Completed   bool        `json:"completed"`
+	CreatedAt   time.Time   `json:"created_at"` //Берем не указатель, потому что нам не нужно менять значение, мы просто в моменте его скопировали и присвоили, все
+	CompletedAt *time.Time  `json:"completed_at,omitempty"`
+	DueAt       *time.Time  `json:"due_at,omitempty"`
+	DueAllDay   bool        `json:"due_all_day,omitempty"` // срок задан датой, без времени
+	TZ          string      `json:"tz,omitempty"`          // IANA-зона, в которой задан срок
+	RemindAt    *time.Time  `json:"remind_at,omitempty"`
+	Recurrence  *Recurrence `json:"recurrence,omitempty"`
+	History     []time.Time `json:"history,omitempty"` // когда выполнялись прошлые повторения
+	NextID      int         `json:"next_id,omitempty"` // следующее повторение, уже созданное при выполнении
+	Priority    string      `json:"priority,omitempty"`
+	Rank        string      `json:"rank,omitempty"` // позиция при ручной сортировке, см. RankBetween
+	Subtasks    []Subtask   `json:"subtasks,omitempty"`
+	Tags        []int       `json:"tags,omitempty"` // ID из tag.Tags
+	// Задача выполняется сама, когда выполнены все подзадачи
+	AutoComplete bool `json:"auto_complete,omitempty"`
+	// Задача в корзине: не видна в списках, пока её не восстановят или не удалят совсем
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Выполненные задачи со временем уходят в архив, см. ArchiveCompletedBefore
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
 
 // Срез для создания списка задач
 type Todos []Todo // По факту мы создаем этакий массив Todos в котором храним элементы типа Todo
 
-func (todos *Todos) Add(title string) {
+// Изменение задачи через PATCH: nil-поля остаются как были
+// Срок и напоминание передаются строкой (см. ParseDue), пустая строка их сбрасывает
+type Patch struct {
+	Title     *string `json:"title"`
+	Completed *bool   `json:"completed"`
+	Due       *string `json:"due"`
+	TZ        *string `json:"tz"`
+	RemindAt  *string `json:"remind_at"`
+	// Freq "" или "none" убирает повторение
+	Recurrence *Recurrence `json:"recurrence"`
+	Priority   *string     `json:"priority"`
+
+	AutoComplete *bool `json:"auto_complete"`
+	// Существование списка и тегов проверяет вызывающий
+	ListID *int   `json:"list_id"`
+	Tags   *[]int `json:"tags"`
+}
+
+// ID не переиспользуются и после удаления из корзины — см. пакет seq
+func (todos Todos) nextID() int {
+	maxID := 0
+	for _, t := range todos {
+		if t.ID > maxID {
+			maxID = t.ID
+		}
+	}
+	return seq.Next("todos", maxID)
+}
+
+func (todos *Todos) Add(title string) Todo {
 	newTask := Todo{
+		ID:        todos.nextID(),
+		ListID:    InboxID,
+		Version:   1,
 		Title:     title,
 		Completed: false,
 		CreatedAt: time.Now(),
+		Rank:      RankAfter(todos.lastRank()),
 	}
 
 	*todos = append(*todos, newTask)
+	return newTask
+}
+
+// Задачи из корзины не находятся — для них есть trashIndex
+func (todos Todos) index(id int) int {
+	for i, t := range todos {
+		if t.ID == id && t.DeletedAt == nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (todos Todos) Find(id int) (*Todo, error) {
+	i := todos.index(id)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	return &todos[i], nil
 }
 
 func (todos Todos) List(completed_filter *bool) { // Берем не указатель, потому что нам не нужно менять значение, мы просто возвращаем копию
@@ -50,30 +127,167 @@ func (todos Todos) List(completed_filter *bool) { // Берем не указа
 	}
 }
 
-func (todos *Todos) Complete(index int) error {
-	if index < 0 || index >= len(*todos) {
-		return errors.New("not index")
+// Для повторяющейся задачи сразу создаётся следующее повторение — его и возвращаем
+func (todos *Todos) Complete(id int) (*Todo, error) {
+	task, err := todos.Find(id)
+	if err != nil {
+		return nil, err
+	}
+	if task.Completed {
+		return nil, nil
 	}
 
-	(*todos)[index].Completed = true
+	task.Completed = true
+	task.Version++
 
 	now := time.Now()
-	(*todos)[index].CompletedAt = &now
+	task.CompletedAt = &now
 	// &now потому что CompletedAt это указатель на *time.Time
+
+	// Повторение создаётся один раз: после Reopen и повторного выполнения новое не нужно
+	if task.Recurrence == nil || task.NextID != 0 {
+		return nil, nil
+	}
+	next := todos.spawnNext(*task, now)
+	// append в spawnNext мог переложить срез, task указывает на старый
+	(*todos)[todos.index(id)].NextID = next.ID
+	return &next, nil
+}
+
+// Снимает отметку о выполнении
+func (todos *Todos) Reopen(id int) error {
+	task, err := todos.Find(id)
+	if err != nil {
+		return err
+	}
+
+	task.Completed = false
+	task.CompletedAt = nil
+	task.ArchivedAt = nil // снова в работе — возвращаем из архива
+	task.Version++
 	return nil
 }
 
-func (todos *Todos) Delete(index int) error {
-	if index < 0 || index >= len(*todos) {
-		return errors.New("not index")
+func (todos *Todos) Update(id int, patch Patch) (*Todo, error) {
+	task, err := todos.Find(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Сначала разбираем всё, что может не пройти проверку, чтобы не изменить задачу наполовину
+	tz := task.TZ
+	if patch.TZ != nil {
+		tz = *patch.TZ
+	}
+	loc, err := LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	var dueAt, remindAt *time.Time
+	var allDay bool
+	if patch.Due != nil && *patch.Due != "" {
+		at, day, err := ParseDue(*patch.Due, loc)
+		if err != nil {
+			return nil, err
+		}
+		dueAt, allDay = &at, day
+	}
+	if patch.RemindAt != nil && *patch.RemindAt != "" {
+		at, _, err := ParseDue(*patch.RemindAt, loc)
+		if err != nil {
+			return nil, ErrInvalidReminder
+		}
+		remindAt = &at
+	}
+	if patch.Priority != nil && !ValidPriority(*patch.Priority) {
+		return nil, ErrInvalidPriority
+	}
+	if patch.Recurrence != nil && patch.Recurrence.Freq != "" && patch.Recurrence.Freq != FreqNone {
+		if err := patch.Recurrence.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if patch.Due == nil && task.DueAt != nil && task.DueAllDay && tz != task.TZ {
+		// Срок-дата хранится как полночь в зоне задачи: при смене зоны это
+		// тот же календарный день, но полночь уже в новой зоне
+		due := task.DueAt.In(task.Location())
+		at := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc)
+		task.DueAt = &at
+	}
+	task.TZ = tz
+	if patch.Due != nil {
+		task.DueAt, task.DueAllDay = dueAt, allDay
+	}
+	if patch.RemindAt != nil {
+		task.RemindAt = remindAt
+	}
+	if patch.Recurrence != nil {
+		task.Recurrence = patch.Recurrence
+		if patch.Recurrence.Freq == "" || patch.Recurrence.Freq == FreqNone {
+			task.Recurrence = nil
+		}
+	}
+	if task.Recurrence != nil && (patch.Recurrence != nil || patch.Due != nil) {
+		task.Recurrence = task.Recurrence.anchored(task.DueAt, loc)
+	}
+
+	if patch.Title != nil {
+		task.Title = *patch.Title
+	}
+	if patch.Priority != nil {
+		task.Priority = *patch.Priority
+		if task.Priority == PriorityNone {
+			task.Priority = ""
+		}
+	}
+	if patch.AutoComplete != nil {
+		task.AutoComplete = *patch.AutoComplete
+	}
+	if patch.ListID != nil {
+		task.ListID = *patch.ListID
+	}
+	if patch.Tags != nil {
+		task.Tags = tag.Normalize(*patch.Tags)
+	}
+	task.Version++
+
+	if patch.Completed != nil && *patch.Completed != task.Completed {
+		if *patch.Completed {
+			_, err = todos.Complete(id)
+		} else {
+			err = todos.Reopen(id)
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	*todos = append((*todos)[:index], (*todos)[index+1:]...)
+	if patch.AutoComplete != nil && *patch.AutoComplete {
+		if err := todos.syncAutoComplete(id, 0); err != nil {
+			return nil, err
+		}
+	}
 
+	// Complete мог добавить повторение и переложить срез — берём задачу заново
+	return todos.Find(id)
+}
+
+// Переносит задачу в корзину. Насовсем удаляет Purge.
+func (todos *Todos) Delete(id int) error {
+	task, err := todos.Find(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	task.DeletedAt = &now
+	task.Version++
 	return nil
 }
 
-// В web версии на данный момент не используется, но List работает через этот метод
+// Через этот метод работают List и Query
 func (todos Todos) Filter(complete_filter *bool) Todos {
 	var result Todos
 
@@ -89,6 +303,8 @@ func (todos Todos) Filter(complete_filter *bool) Todos {
 }
 
 func (todos Todos) Save(login string) error {
+	defer metrics.StorageTimer("todos", "write")()
+
 	dir := filepath.Join(dataDir, login)
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return err
@@ -102,10 +318,15 @@ func (todos Todos) Save(login string) error {
 	defer file.Close()
 
 	encoder := json.NewEncoder(file)
-	return encoder.Encode(todos)
+	if err := encoder.Encode(todos.stored()); err != nil {
+		return err
+	}
+	return seq.Save()
 }
 
 func (todos *Todos) Load(login string) error {
+	defer metrics.StorageTimer("todos", "read")()
+
 	path := filepath.Join(dataDir, login, "todos.json")
 	file, err := os.Open(path)
 	if err != nil {
@@ -118,5 +339,29 @@ func (todos *Todos) Load(login string) error {
 
 	defer file.Close()
 
-	return json.NewDecoder(file).Decode(todos)
+	if err := json.NewDecoder(file).Decode(todos); err != nil {
+		return err
+	}
+
+	// Старые файлы хранили задачи без ID (адресовались по индексу), без списков и рангов.
+	// ID раздаём от максимума в самом файле, а не из seq: чтение ничего не сохраняет,
+	// и при каждом чтении одного и того же файла задачи должны получать одни и те же ID.
+	maxID := 0
+	for _, t := range *todos {
+		maxID = max(maxID, t.ID)
+	}
+	for i := range *todos {
+		if (*todos)[i].ID == 0 {
+			maxID++
+			(*todos)[i].ID = maxID
+		}
+		if (*todos)[i].ListID == 0 {
+			(*todos)[i].ListID = InboxID
+		}
+		if (*todos)[i].Version == 0 {
+			(*todos)[i].Version = 1
+		}
+	}
+	todos.ensureRanks()
+	return nil
 }
@@ -0,0 +1,78 @@
+package todo
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+var ErrNotInTrash = errors.New("в корзине нет такой задачи")
+
+// Задачи не из корзины — всё, что показывается в списках
+func (todos Todos) Alive() Todos {
+	result := Todos{}
+	for _, t := range todos {
+		if t.DeletedAt == nil {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Содержимое корзины, недавно удалённые первыми
+func (todos Todos) Trash() Todos {
+	result := Todos{}
+	for _, t := range todos {
+		if t.DeletedAt != nil {
+			result = append(result, t)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].DeletedAt.After(*result[j].DeletedAt)
+	})
+	return result
+}
+
+func (todos Todos) trashIndex(id int) int {
+	for i, t := range todos {
+		if t.ID == id && t.DeletedAt != nil {
+			return i
+		}
+	}
+	return -1
+}
+
+func (todos Todos) Restore(id int) (*Todo, error) {
+	i := todos.trashIndex(id)
+	if i < 0 {
+		return nil, ErrNotInTrash
+	}
+	todos[i].DeletedAt = nil
+	todos[i].Version++
+	return &todos[i], nil
+}
+
+// Удаляет задачу из корзины насовсем
+func (todos *Todos) Purge(id int) error {
+	i := todos.trashIndex(id)
+	if i < 0 {
+		return ErrNotInTrash
+	}
+	*todos = append((*todos)[:i], (*todos)[i+1:]...)
+	return nil
+}
+
+// Удаляет насовсем всё, что лежит в корзине дольше, чем до before; отдаёт ID удалённых
+func (todos *Todos) PurgeBefore(before time.Time) []int {
+	var purged []int
+	kept := (*todos)[:0]
+	for _, t := range *todos {
+		if t.DeletedAt != nil && t.DeletedAt.Before(before) {
+			purged = append(purged, t.ID)
+			continue
+		}
+		kept = append(kept, t)
+	}
+	*todos = kept
+	return purged
+}
@@ -5,12 +5,106 @@ class TaskFlowApp {
         this.tasks = [];
         this.notes = [];
         this.currentEditingNote = null;
+        this.lang = localStorage.getItem('lang') || '';
+        this.timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone;
+        this.dueFilter = '';
+        this.showArchived = false;
+        this.showArchivedNotes = false;
+        this.sortBy = localStorage.getItem('taskSort') || 'manual';
+        this.lists = [];
+        this.currentList = localStorage.getItem('taskList') || '';
+        this.folderTree = { notes: 0, folders: [] };
+        this.currentFolder = localStorage.getItem('noteFolder') || ''; // '' — все заметки, '0' — корень
+        this.tags = [];
+        this.currentTag = '';
+        this.messages = {};
         this.init();
     }
 
-    init() {
-        this.checkAuth();
+    async init() {
         this.bindEvents();
+        this.bindTaskDragAndDrop();
+        this.bindNoteCheckboxes();
+        document.getElementById('taskSortSelect').value = this.sortBy;
+        await this.loadCatalog();
+        this.checkAuth();
+    }
+
+    // Локализация
+    async loadCatalog() {
+        try {
+            const query = this.lang ? `?lang=${encodeURIComponent(this.lang)}` : '';
+            const response = await fetch('/api/i18n' + query);
+            if (response.ok) {
+                const catalog = await response.json();
+                this.lang = catalog.lang;
+                this.messages = catalog.messages;
+                this.renderLanguageSelects(catalog.languages);
+                this.applyTranslations();
+            }
+        } catch (error) {
+            console.error('Error loading translations:', error);
+        }
+    }
+
+    t(key) {
+        return this.messages[key] || key;
+    }
+
+    applyTranslations() {
+        document.documentElement.lang = this.lang;
+        document.querySelectorAll('[data-i18n]').forEach(el => {
+            el.textContent = this.t(el.dataset.i18n);
+        });
+        document.querySelectorAll('[data-i18n-placeholder]').forEach(el => {
+            el.placeholder = this.t(el.dataset.i18nPlaceholder);
+        });
+    }
+
+    renderLanguageSelects(languages) {
+        document.querySelectorAll('.lang-select').forEach(select => {
+            select.innerHTML = languages
+                .map(lang => `<option value="${lang}">${lang.toUpperCase()}</option>`)
+                .join('');
+            select.value = this.lang;
+            select.title = this.t('common.language');
+        });
+    }
+
+    async setLanguage(lang) {
+        this.lang = lang;
+        localStorage.setItem('lang', lang);
+
+        if (this.currentUser) {
+            try {
+                await fetch('/api/account/language', {
+                    method: 'PUT',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ lang })
+                });
+            } catch (error) {
+                console.error('Error saving language:', error);
+            }
+        }
+
+        await this.loadCatalog();
+        if (this.currentUser) {
+            this.renderTodos();
+            this.renderNotes();
+        }
+    }
+
+    // Текст ошибки из JSON-ответа сервера или запасной вариант
+    async errorMessage(response, fallbackKey) {
+        try {
+            const body = await response.json();
+            if (body.error && body.error.message) {
+                return body.error.message;
+            }
+        } catch (error) {
+            // тело не JSON
+        }
+        return this.t(fallbackKey);
     }
 
     async checkAuth() {
@@ -19,8 +113,11 @@ class TaskFlowApp {
             if (response.ok) {
                 this.currentUser = true;
                 this.showMainScreen();
+                this.loadLists();
+                await this.loadTags();
                 this.loadTodos();
                 this.loadNotes();
+                this.startReminders();
             } else {
                 this.showAuthScreen();
             }
@@ -32,11 +129,12 @@ class TaskFlowApp {
     bindEvents() {
         // Навигация
         document.addEventListener('click', (e) => {
-            if (e.target.classList.contains('nav-item')) {
+            const navItem = e.target.closest('.nav-item');
+            if (navItem) {
                 document.querySelectorAll('.nav-item').forEach(item => {
                     item.classList.remove('active');
                 });
-                e.target.classList.add('active');
+                navItem.classList.add('active');
             }
         });
 
@@ -65,6 +163,9 @@ class TaskFlowApp {
             section.classList.remove('active');
         });
         document.getElementById(sectionName + 'Section').classList.add('active');
+        if (sectionName === 'trash') {
+            this.loadTrash();
+        }
         
         // Закрываем мобильное меню после выбора раздела
         if (window.innerWidth <= 768) {
@@ -99,14 +200,14 @@ class TaskFlowApp {
         const password = document.getElementById('loginPassword').value;
 
         if (!username || !password) {
-            alert('Please fill in all fields');
+            alert(this.t('auth.fill_all_fields'));
             return;
         }
 
         try {
             const response = await fetch('/api/login', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', 'Accept-Language': this.lang },
                 body: JSON.stringify({ login: username, password })
             });
 
@@ -114,14 +215,18 @@ class TaskFlowApp {
                 this.currentUser = username;
                 document.getElementById('usernameDisplay').textContent = username;
                 document.getElementById('mobileUsername').textContent = username;
+                await this.loadCatalog();
                 this.showMainScreen();
+                this.loadLists();
+                await this.loadTags();
                 this.loadTodos();
                 this.loadNotes();
+                this.startReminders();
             } else {
-                alert('Login failed');
+                alert(await this.errorMessage(response, 'auth.login_failed'));
             }
         } catch (error) {
-            alert('Network error');
+            alert(this.t('common.network_error'));
         }
     }
 
@@ -130,25 +235,25 @@ class TaskFlowApp {
         const password = document.getElementById('registerPassword').value;
 
         if (!username || !password) {
-            alert('Please fill in all fields');
+            alert(this.t('auth.fill_all_fields'));
             return;
         }
 
         try {
             const response = await fetch('/api/register', {
                 method: 'POST',
-                headers: { 'Content-Type': 'application/json' },
+                headers: { 'Content-Type': 'application/json', 'Accept-Language': this.lang },
                 body: JSON.stringify({ login: username, password })
             });
 
             if (response.ok) {
-                alert('Account created! Please sign in.');
+                alert(this.t('auth.account_created'));
                 showLoginForm();
             } else {
-                alert('Registration failed');
+                alert(await this.errorMessage(response, 'auth.register_failed'));
             }
         } catch (error) {
-            alert('Network error');
+            alert(this.t('common.network_error'));
         }
     }
 
@@ -157,20 +262,172 @@ class TaskFlowApp {
             // Очищаем куки сессии
             document.cookie = 'session_id=; expires=Thu, 01 Jan 1970 00:00:00 UTC; path=/;';
             this.currentUser = null;
+            clearInterval(this.reminderTimer);
+            this.reminderTimer = null;
             this.showAuthScreen();
         } catch (error) {
             console.error('Logout error:', error);
         }
     }
 
-    // Задачи
-    async loadTodos() {
+    // Списки задач
+    async loadLists() {
         try {
-            const response = await fetch('/api/todos');
+            const response = await fetch('/api/lists');
             if (response.ok) {
-                this.tasks = await response.json();
-                this.renderTodos();
+                this.lists = await response.json();
+                this.renderLists();
+            }
+        } catch (error) {
+            console.error('Error loading lists:', error);
+        }
+    }
+
+    renderLists() {
+        const active = this.lists.filter(list => !list.archived);
+        if (this.currentList && !active.some(list => String(list.id) === this.currentList)) {
+            this.currentList = '';
+        }
+
+        // Названия списков вводит пользователь, поэтому через Option, а не innerHTML
+        const filter = document.getElementById('taskListSelect');
+        filter.replaceChildren(new Option(this.t('lists.all'), ''),
+            ...active.map(list => new Option(list.name, list.id)));
+        filter.value = this.currentList;
+
+        // Новая задача по умолчанию попадает в открытый список
+        const input = document.getElementById('taskListInput');
+        input.replaceChildren(...active.map(list => new Option(list.name, list.id)));
+        input.value = this.currentList || String(this.lists[0]?.id ?? '');
+    }
+
+    setTaskList(listID) {
+        this.currentList = listID;
+        localStorage.setItem('taskList', listID);
+        document.getElementById('taskListInput').value = listID || String(this.lists[0]?.id ?? '');
+        this.loadTodos();
+    }
+
+    async addList() {
+        const name = prompt(this.t('lists.name_prompt'));
+        if (!name) return;
+
+        try {
+            const response = await fetch('/api/lists', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'lists.error_create'));
+                return;
             }
+
+            const list = await response.json();
+            await this.loadLists();
+            this.setTaskList(String(list.id));
+            document.getElementById('taskListSelect').value = this.currentList;
+        } catch (error) {
+            alert(this.t('lists.error_create'));
+        }
+    }
+
+    // Теги
+    async loadTags() {
+        try {
+            const response = await fetch('/api/tags');
+            if (response.ok) {
+                this.tags = await response.json();
+                this.renderTags();
+            }
+        } catch (error) {
+            console.error('Error loading tags:', error);
+        }
+    }
+
+    renderTags() {
+        if (this.currentTag && !this.tags.some(tag => String(tag.id) === this.currentTag)) {
+            this.currentTag = '';
+        }
+
+        const filter = document.getElementById('taskTagSelect');
+        filter.replaceChildren(new Option(this.t('tags.all'), ''),
+            ...this.tags.map(tag => new Option('#' + tag.name, tag.id)));
+        filter.value = this.currentTag;
+
+        const input = document.getElementById('taskTagsInput');
+        input.replaceChildren(...this.tags.map(tag => new Option(tag.name, tag.id)));
+    }
+
+    tagBadges(ids) {
+        return (ids || [])
+            .map(id => this.tags.find(tag => tag.id === id))
+            .filter(Boolean)
+            .map(tag => `<span class="tag-badge" style="${tag.color ? `border-color: ${tag.color}` : ''}">#${this.escapeHTML(tag.name)}</span>`)
+            .join('');
+    }
+
+    // Для пользовательского текста, который вставляется в innerHTML шаблоном
+    escapeHTML(text) {
+        const span = document.createElement('span');
+        span.textContent = text;
+        return span.innerHTML;
+    }
+
+    setTaskTag(tagID) {
+        this.currentTag = tagID;
+        this.loadTodos();
+    }
+
+    async addTag() {
+        const name = prompt(this.t('tags.name_prompt'));
+        if (!name) return;
+
+        try {
+            const response = await fetch('/api/tags', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'tags.error_create'));
+                return;
+            }
+            this.loadTags();
+        } catch (error) {
+            alert(this.t('tags.error_create'));
+        }
+    }
+
+    // Задачи
+    async loadTodos() {
+        const params = new URLSearchParams({ tz: this.timeZone, sort: this.sortBy, limit: 200 });
+        if (this.dueFilter) {
+            params.set('due', this.dueFilter);
+        }
+        if (this.currentList) {
+            params.set('list', this.currentList);
+        }
+        if (this.currentTag) {
+            params.set('tag_id', this.currentTag);
+        }
+        if (this.showArchived) {
+            params.set('archived', 'true');
+        }
+
+        try {
+            // Сервер отдаёт задачи страницами — на доске нужны все
+            const tasks = [];
+            do {
+                const response = await fetch('/api/todos?' + params);
+                if (!response.ok) return;
+                const page = await response.json();
+                tasks.push(...page.items);
+                params.set('cursor', page.next_cursor || '');
+            } while (params.get('cursor'));
+
+            this.tasks = tasks;
+            this.renderTodos();
         } catch (error) {
             console.error('Error loading todos:', error);
         }
@@ -183,8 +440,8 @@ class TaskFlowApp {
         todoList.innerHTML = '';
         completedList.innerHTML = '';
 
-        this.tasks.forEach((task, index) => {
-            const taskElement = this.createTaskElement(task, index);
+        this.tasks.forEach(task => {
+            const taskElement = this.createTaskElement(task);
             if (task.completed) {
                 completedList.appendChild(taskElement);
             } else {
@@ -193,82 +450,382 @@ class TaskFlowApp {
         });
     }
 
-    createTaskElement(task, index) {
+    // Архив показывается вместо обычных задач, фильтры при этом продолжают работать
+    toggleArchive() {
+        this.showArchived = !this.showArchived;
+        document.getElementById('archiveToggle').classList.toggle('active', this.showArchived);
+        this.loadTodos();
+    }
+
+    async setTaskArchived(id, archived) {
+        try {
+            const response = await fetch(`/api/todos/${id}/archive`, { method: archived ? 'PUT' : 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'tasks.error_update'));
+            }
+            this.loadTodos();
+        } catch (error) {
+            alert(this.t('tasks.error_update'));
+        }
+    }
+
+    setDueFilter(due) {
+        this.dueFilter = due;
+        document.querySelectorAll('.task-filters .filter-btn').forEach(btn => {
+            btn.classList.toggle('active', btn.dataset.due === due);
+        });
+        this.loadTodos();
+    }
+
+    setTaskSort(sortBy) {
+        this.sortBy = sortBy;
+        localStorage.setItem('taskSort', sortBy);
+        this.loadTodos();
+    }
+
+    // Перетаскивание в колонке "To Do" — только в ручном порядке
+    bindTaskDragAndDrop() {
+        const list = document.getElementById('todoList');
+        let dragged = null;
+
+        list.addEventListener('dragstart', (e) => {
+            dragged = e.target.closest('.task-item');
+            dragged.classList.add('dragging');
+        });
+
+        list.addEventListener('dragover', (e) => {
+            if (!dragged) return;
+            e.preventDefault();
+            const target = e.target.closest('.task-item');
+            if (!target || target === dragged) return;
+
+            const rect = target.getBoundingClientRect();
+            const after = e.clientY > rect.top + rect.height / 2;
+            list.insertBefore(dragged, after ? target.nextSibling : target);
+        });
+
+        list.addEventListener('drop', async (e) => {
+            e.preventDefault();
+            if (!dragged) return;
+
+            const item = dragged;
+            dragged = null;
+            item.classList.remove('dragging');
+
+            // Сервер пересчитывает ранг только по двум соседям
+            const prev = item.previousElementSibling;
+            const next = item.nextElementSibling;
+            try {
+                const response = await fetch('/api/todos/reorder', {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({
+                        id: Number(item.dataset.id),
+                        prev_id: prev ? Number(prev.dataset.id) : 0,
+                        next_id: next ? Number(next.dataset.id) : 0
+                    })
+                });
+                if (!response.ok) {
+                    alert(await this.errorMessage(response, 'tasks.error_update'));
+                }
+            } catch (error) {
+                alert(this.t('tasks.error_update'));
+            }
+            this.loadTodos();
+        });
+
+        list.addEventListener('dragend', () => {
+            if (dragged) {
+                dragged.classList.remove('dragging');
+                dragged = null;
+                this.renderTodos();
+            }
+        });
+    }
+
+    // Срок-дата действует до конца дня
+    isOverdue(task) {
+        if (task.completed || !task.due_at) return false;
+        const deadline = new Date(task.due_at);
+        if (task.due_all_day) {
+            deadline.setDate(deadline.getDate() + 1);
+        }
+        return deadline < new Date();
+    }
+
+    formatDue(task) {
+        if (!task.due_at) return '';
+        const due = new Date(task.due_at);
+        const text = task.due_all_day
+            ? due.toLocaleDateString(this.lang, { timeZone: task.tz || undefined })
+            : due.toLocaleString(this.lang, { dateStyle: 'short', timeStyle: 'short' });
+        const label = this.isOverdue(task) ? this.t('tasks.overdue') : this.t('tasks.due');
+        return `${label} ${text}`;
+    }
+
+    createTaskElement(task) {
         const taskDiv = document.createElement('div');
-        taskDiv.className = 'task-item';
+        taskDiv.className = 'task-item' + (this.isOverdue(task) ? ' overdue' : '');
+        taskDiv.dataset.id = task.id;
+        taskDiv.draggable = this.sortBy === 'manual' && !task.completed;
+        let archive = '';
+        if (task.archived_at) {
+            archive = `<button class="delete-btn" title="${this.t('archive.restore')}" onclick="app.setTaskArchived(${task.id}, false)">↩️</button>`;
+        } else if (task.completed) {
+            archive = `<button class="delete-btn" title="${this.t('archive.move')}" onclick="app.setTaskArchived(${task.id}, true)">📦</button>`;
+        }
+        const priority = task.priority
+            ? `<span class="priority-badge priority-${task.priority}">${this.t('tasks.priority_' + task.priority)}</span>`
+            : '';
         taskDiv.innerHTML = `
             <div class="task-checkbox ${task.completed ? 'checked' : ''}" 
-                 onclick="app.toggleTask(${index})"></div>
+                 onclick="app.toggleTask(${task.id})"></div>
             <div class="task-content">
-                <div class="task-title">${task.title}</div>
-                <div class="task-date">${new Date(task.created_at).toLocaleDateString()}</div>
+                <div class="task-title" ondblclick="app.renameTask(${task.id})">${task.recurrence ? '🔁 ' : ''}${task.title}${priority}${this.tagBadges(task.tags)}</div>
+                <div class="task-date">${new Date(task.created_at).toLocaleDateString(this.lang)}</div>
+                ${task.due_at ? `<div class="task-due">${this.formatDue(task)}</div>` : ''}
+                ${task.progress ? `<div class="task-progress">${task.progress.done}/${task.progress.total}</div>` : ''}
+            </div>
+            <div class="task-actions">
+                <button class="delete-btn" title="${this.t('tasks.add_subtask')}" onclick="app.addSubtask(${task.id}, 0)">＋</button>
+                ${archive}
+                <button class="delete-btn" onclick="app.deleteTask(${task.id})">🗑️</button>
             </div>
-            <button class="delete-btn" onclick="app.deleteTask(${index})">🗑️</button>
         `;
+        const subtasks = this.renderSubtasks(task.id, task.subtasks || [], 1);
+        if (subtasks) taskDiv.querySelector('.task-content').appendChild(subtasks);
         return taskDiv;
     }
 
+    // Названия подзадач вводит пользователь — только через textContent
+    renderSubtasks(taskId, subtasks, depth) {
+        if (!subtasks.length) return null;
+        const list = document.createElement('ul');
+        list.className = 'subtask-list';
+        subtasks.forEach(sub => {
+            const item = document.createElement('li');
+            item.innerHTML = `
+                <span class="subtask-checkbox ${sub.completed ? 'checked' : ''}"
+                      onclick="app.toggleSubtask(${taskId}, ${sub.id}, ${!sub.completed})"></span>
+                <span class="subtask-title"></span>
+                ${sub.progress ? `<span class="task-progress">${sub.progress.done}/${sub.progress.total}</span>` : ''}
+                ${depth < 3 ? `<button class="delete-btn" onclick="app.addSubtask(${taskId}, ${sub.id})">＋</button>` : ''}
+                <button class="delete-btn" onclick="app.deleteSubtask(${taskId}, ${sub.id})">✕</button>`;
+            item.querySelector('.subtask-title').textContent = sub.title;
+            const nested = this.renderSubtasks(taskId, sub.subtasks || [], depth + 1);
+            if (nested) item.appendChild(nested);
+            list.appendChild(item);
+        });
+        return list;
+    }
+
+    async addSubtask(taskId, parentId) {
+        const title = prompt(this.t('tasks.subtask_prompt'));
+        if (!title) return;
+
+        try {
+            const response = await fetch(`/api/todos/${taskId}/subtasks`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ title, parent_id: parentId })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'tasks.error_update'));
+            }
+            this.loadTodos();
+        } catch (error) {
+            alert(this.t('tasks.error_update'));
+        }
+    }
+
+    async toggleSubtask(taskId, subtaskId, completed) {
+        try {
+            await fetch(`/api/todos/${taskId}/subtasks/${subtaskId}`, {
+                method: 'PATCH',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ completed })
+            });
+            this.loadTodos();
+        } catch (error) {
+            alert(this.t('tasks.error_update'));
+        }
+    }
+
+    async deleteSubtask(taskId, subtaskId) {
+        try {
+            await fetch(`/api/todos/${taskId}/subtasks/${subtaskId}`, { method: 'DELETE' });
+            this.loadTodos();
+        } catch (error) {
+            alert(this.t('tasks.error_delete'));
+        }
+    }
+
+    // Версия задачи уходит в If-Match: если задачу изменили в другой вкладке, сервер ответит 412
+    async patchTask(id, changes) {
+        const task = this.tasks.find(t => t.id === id);
+        const response = await fetch(`/api/todos/${id}`, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json', 'If-Match': `"${task?.version ?? ''}"` },
+            body: JSON.stringify(changes)
+        });
+        if (!response.ok) {
+            if (response.status === 412) {
+                this.loadTodos();
+            }
+            throw new Error(await this.errorMessage(response, 'tasks.error_update'));
+        }
+        return response.json();
+    }
+
+    async renameTask(id) {
+        const task = this.tasks.find(t => t.id === id);
+        if (!task) return;
+
+        const title = prompt(this.t('tasks.rename_prompt'), task.title);
+        if (!title || title === task.title) return;
+
+        try {
+            await this.patchTask(id, { title });
+            this.loadTodos();
+        } catch (error) {
+            alert(error.message);
+        }
+    }
+
     async addTask() {
         const title = document.getElementById('taskTitleInput').value;
         if (!title) return;
 
+        const dueDate = document.getElementById('taskDueDateInput').value;
+        const dueTime = document.getElementById('taskDueTimeInput').value;
+        const remindAt = document.getElementById('taskRemindInput').value;
+        const repeat = document.getElementById('taskRepeatInput').value;
+        const priority = document.getElementById('taskPriorityInput').value;
+        const listID = Number(document.getElementById('taskListInput').value);
+        const tags = [...document.getElementById('taskTagsInput').selectedOptions].map(option => Number(option.value));
+
+        // Без времени срок ставится на весь день
+        const body = { title, tz: this.timeZone };
+        if (dueDate) {
+            body.due = dueTime ? `${dueDate}T${dueTime}` : dueDate;
+        }
+        if (remindAt) {
+            body.remind_at = remindAt;
+        }
+        if (repeat) {
+            body.recurrence = { freq: repeat };
+        }
+        if (priority) {
+            body.priority = priority;
+        }
+        if (listID) {
+            body.list_id = listID;
+        }
+        if (tags.length) {
+            body.tags = tags;
+        }
+
         try {
-            await fetch('/api/todos', {
+            const response = await fetch('/api/todos', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ title })
+                body: JSON.stringify(body)
             });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'tasks.error_create'));
+                return;
+            }
 
             this.hideModals();
-            document.getElementById('taskTitleInput').value = '';
+            ['taskTitleInput', 'taskDueDateInput', 'taskDueTimeInput', 'taskRemindInput', 'taskRepeatInput', 'taskPriorityInput'].forEach(id => {
+                document.getElementById(id).value = '';
+            });
+            document.getElementById('taskTagsInput').selectedIndex = -1;
             this.loadTodos();
         } catch (error) {
-            alert('Error creating task');
+            alert(this.t('tasks.error_create'));
         }
     }
 
-    async toggleTask(index) {
+    async toggleTask(id) {
+        const task = this.tasks.find(t => t.id === id);
+        if (!task) return;
+
         try {
-            await fetch(`/api/todos/${index}/complete`, {
-                method: 'PUT'
-            });
+            // Выполненную задачу можно вернуть обратно в работу
+            await this.patchTask(id, { completed: !task.completed });
             this.loadTodos();
         } catch (error) {
-            alert('Error updating task');
+            alert(error.message);
         }
     }
 
-    async deleteTask(index) {
+    async deleteTask(id) {
         try {
-            await fetch(`/api/todos/${index}`, {
-                method: 'DELETE'
+            await fetch(`/api/todos/${id}`, {
+                method: 'DELETE',
+                headers: { 'API-Version': '2' } // без заголовка сервер понимает id как индекс в списке
             });
             this.loadTodos();
         } catch (error) {
-            alert('Error deleting task');
+            alert(this.t('tasks.error_delete'));
+        }
+    }
+
+    // Напоминания: опрашиваем сервер раз в минуту, каждое показываем один раз
+    startReminders() {
+        if (this.reminderTimer) return;
+        if ('Notification' in window && Notification.permission === 'default') {
+            Notification.requestPermission();
+        }
+        this.checkReminders();
+        this.reminderTimer = setInterval(() => this.checkReminders(), 60000);
+    }
+
+    async checkReminders() {
+        if (!this.currentUser) return;
+
+        try {
+            const response = await fetch('/api/todos/reminders');
+            if (!response.ok) return;
+
+            const shown = JSON.parse(localStorage.getItem('shownReminders') || '[]');
+            const due = await response.json();
+            due.forEach(task => {
+                const key = `${task.id}@${task.remind_at}`;
+                if (shown.includes(key)) return;
+                shown.push(key);
+
+                const text = `${this.t('tasks.reminder')}: ${task.title}`;
+                if ('Notification' in window && Notification.permission === 'granted') {
+                    new Notification(text);
+                } else {
+                    alert(text);
+                }
+            });
+            localStorage.setItem('shownReminders', JSON.stringify(shown.slice(-200)));
+        } catch (error) {
+            console.error('Error loading reminders:', error);
         }
     }
 
     // Заметки
     async loadNotes() {
+        const params = new URLSearchParams();
+        if (this.showArchivedNotes) {
+            params.set('archived', 'true');
+        }
+        if (this.currentFolder) {
+            params.set('folder', this.currentFolder);
+        }
+
+        // Числа заметок в папках меняются вместе со списком
+        this.loadFolders();
         try {
-            const response = await fetch('/api/notes');
+            const response = await fetch('/api/notes?' + params);
             if (response.ok) {
-                const notePreviews = await response.json();
-                // Загружаем полные данные для каждой заметки
-                this.notes = await Promise.all(
-                    notePreviews.map(async (preview) => {
-                        try {
-                            const fullNoteResponse = await fetch(`/api/notes/${preview.id}`);
-                            if (fullNoteResponse.ok) {
-                                return await fullNoteResponse.json();
-                            }
-                        } catch (error) {
-                            console.error('Error loading full note:', error);
-                        }
-                        return preview;
-                    })
-                );
+                // Для карточек хватает выдержки, полная заметка грузится при открытии
+                this.notes = await response.json();
                 this.renderNotes();
             }
         } catch (error) {
@@ -282,48 +839,626 @@ class TaskFlowApp {
 
         this.notes.forEach(note => {
             const noteElement = document.createElement('div');
-            noteElement.className = 'note-item';
-            
-            // Исправляем поле updated_at (в бэкенде оно называется update_at)
-            const updatedAt = note.update_at || note.updated_at;
-            const createdAt = note.created_at;
+            noteElement.className = 'note-item' + (note.pinned ? ' pinned' : '') + (note.color ? ' colored' : '');
+            if (note.color) {
+                noteElement.style.borderTopColor = note.color;
+            }
             
             noteElement.innerHTML = `
                 <div class="note-header">
-                    <div class="note-title">${note.title}</div>
-                    <button class="delete-btn" onclick="event.stopPropagation(); app.deleteNote(${note.id})">🗑️</button>
+                    <div class="note-title">${note.title}${this.tagBadges(note.tags)}</div>
+                    <div class="note-actions" onclick="event.stopPropagation()">
+                        <button class="delete-btn ${note.pinned ? 'active' : ''}" title="${this.t(note.pinned ? 'notes.unpin' : 'notes.pin')}"
+                                onclick="app.setNoteFlag(${note.id}, 'pin', ${!note.pinned})">📌</button>
+                        <button class="delete-btn" title="${this.t(note.favorite ? 'notes.unfavorite' : 'notes.favorite')}"
+                                onclick="app.setNoteFlag(${note.id}, 'favorite', ${!note.favorite})">${note.favorite ? '★' : '☆'}</button>
+                        <input type="color" class="note-color" title="${this.t('notes.color')}" value="${note.color || '#ffffff'}"
+                               onchange="app.setNoteColor(${note.id}, this.value)">
+                        <button class="delete-btn" title="${this.t(note.archived_at ? 'archive.restore' : 'archive.move')}"
+                                onclick="app.setNoteArchived(${note.id}, ${!note.archived_at})">${note.archived_at ? '↩️' : '📦'}</button>
+                        <button class="delete-btn" onclick="app.deleteNote(${note.id})">🗑️</button>
+                    </div>
                 </div>
-                <div class="note-content-preview">${note.content || 'No content yet...'}</div>
+                <div class="note-content-preview"></div>
                 <div class="note-date">
-                    Updated: ${new Date(updatedAt).toLocaleDateString()}
+                    ${this.t('notes.updated')} ${new Date(note.updated_at).toLocaleDateString(this.lang)}
+                    · ${note.words} ${this.t('notes.words')} · ${note.reading_minutes} ${this.t('notes.minutes')}
+                    ${note.attachments ? `· 📎 ${note.attachments}` : ''}
                 </div>
             `;
             
             // Добавляем обработчик клика для просмотра полной заметки
+            noteElement.querySelector('.note-content-preview').textContent = note.excerpt || this.t('notes.empty');
+
             noteElement.addEventListener('click', () => {
-                this.viewNote(note);
+                this.openNote(note.id);
             });
             
             notesGrid.appendChild(noteElement);
         });
     }
 
+    // flag — pin или favorite, у каждого свой адрес: PUT ставит, DELETE снимает
+    async setNoteFlag(id, flag, on) {
+        try {
+            const response = await fetch(`/api/notes/${id}/${flag}`, { method: on ? 'PUT' : 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'notes.error_update'));
+            }
+            this.loadNotes();
+        } catch (error) {
+            alert(this.t('notes.error_update'));
+        }
+    }
+
+    async setNoteColor(id, color) {
+        try {
+            const response = await fetch(`/api/notes/${id}/color`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ color })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'notes.error_update'));
+            }
+            this.loadNotes();
+        } catch (error) {
+            alert(this.t('notes.error_update'));
+        }
+    }
+
+    // Шаблоны заметок. Подстановки {{date}}, {{time}}, {{weekday}} раскрывает сервер.
+    async loadTemplates() {
+        try {
+            const response = await fetch('/api/templates');
+            if (response.ok) {
+                const templates = await response.json();
+                const select = document.getElementById('noteTemplateSelect');
+                const current = select.value;
+                select.replaceChildren(new Option(this.t('templates.none'), ''), ...templates.map(t => new Option(t.name, t.id)));
+                select.value = templates.some(t => String(t.id) === current) ? current : '';
+            }
+        } catch (error) {
+            console.error('Error loading templates:', error);
+        }
+    }
+
+    async saveNoteAsTemplate() {
+        const note = this.currentEditingNote;
+        if (!note) return;
+        const name = prompt(this.t('templates.name_prompt'), note.title);
+        if (!name) return;
+
+        try {
+            const response = await fetch('/api/templates', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name, title: note.title, content: note.content })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'templates.error'));
+                return;
+            }
+            alert(this.t('templates.saved'));
+        } catch (error) {
+            alert(this.t('templates.error'));
+        }
+    }
+
+    async deleteTemplate() {
+        const id = document.getElementById('noteTemplateSelect').value;
+        if (!id || !confirm(this.t('templates.confirm_delete'))) return;
+
+        try {
+            const response = await fetch(`/api/templates/${id}`, { method: 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'templates.error'));
+            }
+            this.loadTemplates();
+        } catch (error) {
+            alert(this.t('templates.error'));
+        }
+    }
+
+    // Папки
+    async loadFolders() {
+        try {
+            const response = await fetch('/api/folders/tree');
+            if (response.ok) {
+                this.folderTree = await response.json();
+                this.renderFolders();
+            }
+        } catch (error) {
+            console.error('Error loading folders:', error);
+        }
+    }
+
+    // Дерево в плоский список с отступами — для select
+    flattenFolders(nodes, depth = 0) {
+        return nodes.flatMap(node => [
+            { id: node.id, label: '— '.repeat(depth) + node.name, notes: node.notes },
+            ...this.flattenFolders(node.children, depth + 1)
+        ]);
+    }
+
+    renderFolders() {
+        const folders = this.flattenFolders(this.folderTree.folders);
+        if (this.currentFolder && this.currentFolder !== '0' && !folders.some(f => String(f.id) === this.currentFolder)) {
+            this.currentFolder = '';
+        }
+
+        // Названия папок вводит пользователь, поэтому через Option, а не innerHTML
+        const filter = document.getElementById('noteFolderSelect');
+        filter.replaceChildren(
+            new Option(this.t('folders.all'), ''),
+            new Option(`${this.t('folders.root')} (${this.folderTree.notes})`, '0'),
+            ...folders.map(f => new Option(`${f.label} (${f.notes})`, f.id)));
+        filter.value = this.currentFolder;
+
+        const move = document.getElementById('viewNoteFolder');
+        move.replaceChildren(new Option(this.t('folders.root'), '0'), ...folders.map(f => new Option(f.label, f.id)));
+        if (this.currentEditingNote) {
+            move.value = String(this.currentEditingNote.folder_id ?? 0);
+        }
+    }
+
+    setNoteFolder(folderID) {
+        this.currentFolder = folderID;
+        localStorage.setItem('noteFolder', folderID);
+        this.loadNotes();
+    }
+
+    // Открытая папка, в которую попадут новые заметки и папки
+    folderID() {
+        return Number(this.currentFolder) || 0;
+    }
+
+    async addFolder() {
+        const name = prompt(this.t('folders.name_prompt'));
+        if (!name) return;
+
+        try {
+            const response = await fetch('/api/folders', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name, parent_id: this.folderID() })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'folders.error'));
+                return;
+            }
+            const folder = await response.json();
+            this.setNoteFolder(String(folder.id));
+        } catch (error) {
+            alert(this.t('folders.error'));
+        }
+    }
+
+    async renameFolder() {
+        const folder = this.flattenFolders(this.folderTree.folders).find(f => f.id === this.folderID());
+        if (!folder) return;
+        const name = prompt(this.t('folders.name_prompt'), folder.label.replace(/^(— )+/, ''));
+        if (!name) return;
+
+        try {
+            const response = await fetch(`/api/folders/${folder.id}`, {
+                method: 'PATCH',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ name })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'folders.error'));
+            }
+            this.loadFolders();
+        } catch (error) {
+            alert(this.t('folders.error'));
+        }
+    }
+
+    // OK во втором вопросе — заметки в корзину вместе с папкой, отмена — заметки и подпапки поднимаются выше
+    async deleteFolder() {
+        const id = this.folderID();
+        if (!id || !confirm(this.t('folders.confirm_delete'))) return;
+        const mode = confirm(this.t('folders.confirm_cascade')) ? 'cascade' : 'reparent';
+
+        try {
+            const response = await fetch(`/api/folders/${id}?mode=${mode}`, { method: 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'folders.error'));
+                return;
+            }
+            this.setNoteFolder('');
+        } catch (error) {
+            alert(this.t('folders.error'));
+        }
+    }
+
+    async moveNoteToFolder(folderID) {
+        const note = this.currentEditingNote;
+        if (!note) return;
+
+        try {
+            const response = await fetch(`/api/notes/${note.id}/folder`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ folder_id: Number(folderID) })
+            });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'folders.error'));
+                return;
+            }
+            const moved = await response.json();
+            note.version = moved.version;
+            note.folder_id = moved.folder_id;
+            this.loadNotes();
+        } catch (error) {
+            alert(this.t('folders.error'));
+        }
+    }
+
+    toggleNotesArchive() {
+        this.showArchivedNotes = !this.showArchivedNotes;
+        document.getElementById('notesArchiveToggle').classList.toggle('active', this.showArchivedNotes);
+        this.loadNotes();
+    }
+
+    async setNoteArchived(id, archived) {
+        try {
+            const response = await fetch(`/api/notes/${id}/archive`, { method: archived ? 'PUT' : 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'notes.error_update'));
+            }
+            this.loadNotes();
+        } catch (error) {
+            alert(this.t('notes.error_update'));
+        }
+    }
+
+    // Корзина
+    async loadTrash() {
+        try {
+            const response = await fetch('/api/trash');
+            if (!response.ok) return;
+            const trash = await response.json();
+            this.renderTrash('trashTodoList', 'todos', trash.todos);
+            this.renderTrash('trashNoteList', 'notes', trash.notes);
+        } catch (error) {
+            console.error('Error loading trash:', error);
+        }
+    }
+
+    renderTrash(containerId, kind, items) {
+        const container = document.getElementById(containerId);
+        container.replaceChildren(...items.map(item => {
+            const div = document.createElement('div');
+            div.className = 'task-item';
+
+            const content = document.createElement('div');
+            content.className = 'task-content';
+            const title = document.createElement('div');
+            title.className = 'task-title';
+            title.textContent = item.title;
+            const date = document.createElement('div');
+            date.className = 'task-date';
+            date.textContent = `${this.t('trash.deleted')} ${new Date(item.deleted_at).toLocaleString(this.lang)}`;
+            content.append(title, date);
+
+            const restore = document.createElement('button');
+            restore.className = 'secondary-btn';
+            restore.textContent = this.t('trash.restore');
+            restore.onclick = () => this.trashAction(`/api/trash/${kind}/${item.id}/restore`, 'POST');
+            const purge = document.createElement('button');
+            purge.className = 'delete-btn';
+            purge.textContent = '✖';
+            purge.title = this.t('trash.delete_forever');
+            purge.onclick = () => {
+                if (confirm(this.t('trash.confirm_delete'))) {
+                    this.trashAction(`/api/trash/${kind}/${item.id}`, 'DELETE');
+                }
+            };
+
+            div.append(content, restore, purge);
+            return div;
+        }));
+    }
+
+    async trashAction(url, method) {
+        try {
+            const response = await fetch(url, { method });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'trash.error'));
+            }
+        } catch (error) {
+            alert(this.t('common.network_error'));
+        }
+        this.loadTrash();
+        this.loadTodos();
+        this.loadNotes();
+    }
+
+    emptyTrash() {
+        if (confirm(this.t('trash.confirm_empty'))) {
+            this.trashAction('/api/trash', 'DELETE');
+        }
+    }
+
+    // Поиск по заметкам и задачам
+    searchAll(query) {
+        clearTimeout(this.searchTimer);
+        this.searchTimer = setTimeout(() => this.runSearch(query.trim()), 250);
+    }
+
+    async runSearch(query) {
+        const container = document.getElementById('searchResults');
+        if (!query) {
+            container.replaceChildren();
+            return;
+        }
+
+        try {
+            const response = await fetch('/api/search?' + new URLSearchParams({ q: query }));
+            if (!response.ok) return;
+            const results = await response.json();
+
+            container.replaceChildren(...results.map(result => {
+                const item = document.createElement('div');
+                item.className = 'search-result';
+
+                const kind = document.createElement('span');
+                kind.className = 'search-result-kind';
+                kind.textContent = this.t('search.kind_' + result.kind);
+                const title = document.createElement('strong');
+                title.textContent = result.title;
+
+                // Сниппет сервер уже экранировал, в нём только <mark>
+                const snippet = document.createElement('div');
+                snippet.className = 'search-result-snippet';
+                snippet.innerHTML = result.snippet;
+
+                item.append(kind, title, snippet);
+                item.addEventListener('click', () => this.openSearchResult(result));
+                return item;
+            }));
+            if (!results.length) {
+                container.textContent = this.t('search.nothing_found');
+            }
+        } catch (error) {
+            console.error('Error searching:', error);
+        }
+    }
+
+    async openSearchResult(result) {
+        if (result.kind === 'todo') {
+            this.showSection('tasks');
+            return;
+        }
+
+        this.openNote(result.id);
+    }
+
+    async openNote(id) {
+        try {
+            const response = await fetch(`/api/notes/${id}`);
+            if (response.ok) {
+                this.viewNote(await response.json());
+            }
+        } catch (error) {
+            console.error('Error loading note:', error);
+        }
+    }
+
     viewNote(note) {
         // Исправляем поле updated_at (в бэкенде оно называется update_at)
         const updatedAt = note.update_at || note.updated_at;
         const createdAt = note.created_at;
         
         document.getElementById('viewNoteTitle').textContent = note.title;
-        document.getElementById('viewNoteContent').textContent = note.content || 'No content yet...';
+        const content = document.getElementById('viewNoteContent');
+        content.classList.remove('markdown');
+        content.textContent = note.content || this.t('notes.empty');
+        if (note.content) {
+            this.renderNoteMarkdown(note.id);
+        }
         document.getElementById('viewNoteCreatedAt').textContent = new Date(createdAt).toLocaleString();
         document.getElementById('viewNoteUpdatedAt').textContent = new Date(updatedAt).toLocaleString();
         
         // Сохраняем текущую заметку для возможного редактирования
         this.currentEditingNote = note;
+        document.getElementById('noteHistory').replaceChildren();
+        this.renderAttachments(note);
+        this.renderBacklinks(note.id);
+        this.renderNoteTodos(note.id);
+        document.getElementById('viewNoteFolder').value = String(note.folder_id ?? 0);
         
         this.showModal('viewNoteModal');
     }
 
+    renderAttachments(note) {
+        const container = document.getElementById('noteAttachments');
+        container.replaceChildren(...(note.attachments || []).map(a => {
+            const row = document.createElement('div');
+            row.className = 'attachment-item';
+
+            const link = document.createElement('a');
+            link.href = `/api/notes/${note.id}/attachments/${a.id}`;
+            link.target = '_blank';
+            link.textContent = `📎 ${a.name}`;
+            const size = document.createElement('span');
+            size.className = 'attachment-size';
+            size.textContent = this.formatSize(a.size);
+            const remove = document.createElement('button');
+            remove.className = 'delete-btn';
+            remove.textContent = '✖';
+            remove.onclick = () => this.deleteAttachment(note.id, a.id);
+
+            row.append(link, size, remove);
+            return row;
+        }));
+    }
+
+    formatSize(bytes) {
+        if (bytes < 1024) return `${bytes} B`;
+        if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+        return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+    }
+
+    async uploadAttachment(input) {
+        const note = this.currentEditingNote;
+        const file = input.files[0];
+        input.value = '';
+        if (!note || !file) return;
+
+        const form = new FormData();
+        form.append('file', file);
+        try {
+            const response = await fetch(`/api/notes/${note.id}/attachments`, { method: 'POST', body: form });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'attachments.error'));
+                return;
+            }
+            this.refreshAttachments(note.id);
+        } catch (error) {
+            alert(this.t('attachments.error'));
+        }
+    }
+
+    async deleteAttachment(noteId, attachmentId) {
+        if (!confirm(this.t('attachments.confirm'))) return;
+
+        try {
+            const response = await fetch(`/api/notes/${noteId}/attachments/${attachmentId}`, { method: 'DELETE' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'attachments.error'));
+                return;
+            }
+            this.refreshAttachments(noteId);
+        } catch (error) {
+            alert(this.t('attachments.error'));
+        }
+    }
+
+    // Вложения меняют версию заметки — обновляем её, иначе следующая правка получит 412
+    async refreshAttachments(id) {
+        const response = await fetch(`/api/notes/${id}`);
+        if (!response.ok || this.currentEditingNote?.id !== id) return;
+        const note = await response.json();
+        this.currentEditingNote.version = note.version;
+        this.currentEditingNote.attachments = note.attachments;
+        this.renderAttachments(note);
+    }
+
+    // Markdown рендерит сервер, HTML уже очищен от всего опасного
+    async renderNoteMarkdown(id) {
+        try {
+            const response = await fetch(`/api/notes/${id}?format=html`);
+            if (!response.ok || this.currentEditingNote?.id !== id) return;
+            this.showNoteHTML(await response.json());
+        } catch (error) {
+            console.error('Error rendering note:', error);
+        }
+    }
+
+    showNoteHTML(note) {
+        this.currentEditingNote = note;
+        const content = document.getElementById('viewNoteContent');
+        content.classList.add('markdown');
+        content.innerHTML = note.html;
+    }
+
+    bindNoteCheckboxes() {
+        document.getElementById('viewNoteContent').addEventListener('change', async (e) => {
+            const box = e.target.closest('input[data-checkbox]');
+            const note = this.currentEditingNote;
+            if (!box || !note) return;
+
+            try {
+                const response = await fetch(`/api/notes/${note.id}/checkboxes/${box.dataset.checkbox}?format=html`, {
+                    method: 'PUT',
+                    headers: { 'Content-Type': 'application/json', 'If-Match': `"${note.version}"` },
+                    body: JSON.stringify({ checked: box.checked })
+                });
+                if (!response.ok) {
+                    box.checked = !box.checked;
+                    alert(await this.errorMessage(response, 'notes.error_update'));
+                    return;
+                }
+                this.showNoteHTML(await response.json());
+                this.loadNotes();
+                // Пункт мог быть связан с задачей — её состояние тоже поменялось
+                this.loadTodos();
+                this.renderNoteTodos(note.id);
+            } catch (error) {
+                box.checked = !box.checked;
+                alert(this.t('common.network_error'));
+            }
+        });
+    }
+
+    // История версий: список с кнопками "сравнить" и "восстановить"
+    async showNoteHistory() {
+        const note = this.currentEditingNote;
+        if (!note) return;
+
+        const response = await fetch(`/api/notes/${note.id}/revisions`);
+        if (!response.ok) return;
+        const revisions = await response.json();
+        const latest = revisions[0]?.rev;
+
+        const container = document.getElementById('noteHistory');
+        container.replaceChildren(...revisions.map(revision => {
+            const item = document.createElement('div');
+            item.className = 'revision-item';
+
+            const label = document.createElement('span');
+            label.textContent = `#${revision.rev} ${revision.title} — ${new Date(revision.created_at).toLocaleString(this.lang)}`;
+            item.append(label);
+
+            if (revision.rev !== latest) {
+                const actions = document.createElement('span');
+                const diff = document.createElement('button');
+                diff.className = 'secondary-btn';
+                diff.textContent = this.t('notes.compare');
+                diff.onclick = () => this.showRevisionDiff(note.id, revision.rev);
+                const restore = document.createElement('button');
+                restore.className = 'secondary-btn';
+                restore.textContent = this.t('notes.restore');
+                restore.onclick = () => this.restoreRevision(note.id, note.version, revision.rev);
+                actions.append(diff, restore);
+                item.append(actions);
+            }
+            return item;
+        }));
+    }
+
+    // Разница между версией и текущим состоянием
+    async showRevisionDiff(noteID, rev) {
+        const response = await fetch(`/api/notes/${noteID}/revisions/diff?from=${rev}`);
+        if (!response.ok) return;
+        const diff = await response.json();
+
+        document.getElementById('noteHistory').replaceChildren(...diff.lines.map(line => {
+            const div = document.createElement('div');
+            div.className = 'diff-line ' + line.op;
+            div.textContent = (line.op === 'insert' ? '+ ' : line.op === 'delete' ? '- ' : '  ') + line.text;
+            return div;
+        }));
+    }
+
+    async restoreRevision(noteID, version, rev) {
+        if (!confirm(this.t('notes.confirm_restore'))) return;
+
+        const response = await fetch(`/api/notes/${noteID}/revisions/${rev}/restore`, {
+            method: 'POST',
+            headers: { 'If-Match': `"${version}"` }
+        });
+        if (!response.ok) {
+            alert(await this.errorMessage(response, 'notes.error_update'));
+            return;
+        }
+        this.viewNote(await response.json());
+        this.loadNotes();
+    }
+
     enableNoteEdit() {
         if (!this.currentEditingNote) return;
         
@@ -341,25 +1476,123 @@ class TaskFlowApp {
         const content = document.getElementById('editNoteContent').value;
 
         if (!title) {
-            alert('Note title is required');
+            alert(this.t('notes.title_required'));
             return;
         }
 
         try {
             const response = await fetch(`/api/notes/${this.currentEditingNote.id}`, {
                 method: 'PUT',
-                headers: { 'Content-Type': 'application/json' },
+                headers: {
+                    'Content-Type': 'application/json',
+                    'If-Match': `"${this.currentEditingNote.version}"`
+                },
                 body: JSON.stringify({ title, content })
             });
 
             if (response.ok) {
+                const { id, title: oldTitle } = this.currentEditingNote;
                 this.hideModals();
+                if (oldTitle !== title) {
+                    await this.offerLinkRewrite(id, oldTitle, response.headers.get('ETag'));
+                }
                 this.loadNotes();
             } else {
-                alert('Error updating note');
+                // 412 — заметку уже изменили в другой вкладке; текст в форме не трогаем
+                alert(await this.errorMessage(response, 'notes.error_update'));
+                if (response.status === 412) {
+                    this.loadNotes();
+                }
+            }
+        } catch (error) {
+            alert(this.t('common.network_error'));
+        }
+    }
+
+    // После переименования ссылки [[старый заголовок]] в других заметках ломаются —
+    // предлагаем перевести их на новый заголовок
+    async offerLinkRewrite(id, oldTitle, etag) {
+        try {
+            const response = await fetch('/api/notes/broken-links');
+            if (!response.ok) return;
+            const broken = (await response.json())
+                .filter(link => link.target.trim().toLowerCase() === oldTitle.trim().toLowerCase());
+            if (!broken.length || !confirm(`${this.t('links.rewrite_confirm')} (${broken.length})`)) return;
+
+            await fetch(`/api/notes/${id}/rewrite-links`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json', 'If-Match': etag },
+                body: JSON.stringify({ from: oldTitle })
+            });
+        } catch (error) {
+            console.error('Error rewriting links:', error);
+        }
+    }
+
+    async renderNoteTodos(id) {
+        const container = document.getElementById('noteTodos');
+        container.replaceChildren();
+        try {
+            const response = await fetch(`/api/notes/${id}/todos`);
+            if (!response.ok || this.currentEditingNote?.id !== id) return;
+            const todos = await response.json();
+            if (!todos.length) return;
+
+            const label = document.createElement('span');
+            label.textContent = this.t('links.todos');
+            container.replaceChildren(label, ...todos.map(task => {
+                const item = document.createElement('span');
+                item.className = 'linked-todo' + (task.completed ? ' done' : '');
+                item.textContent = (task.completed ? '☑ ' : '☐ ') + task.title;
+                return item;
+            }));
+        } catch (error) {
+            console.error('Error loading linked todos:', error);
+        }
+    }
+
+    // Задачи из невыполненных пунктов-чекбоксов; уже созданные сервер пропускает
+    async createTodosFromNote() {
+        const note = this.currentEditingNote;
+        if (!note) return;
+
+        try {
+            const response = await fetch(`/api/notes/${note.id}/checkboxes/todos`, { method: 'POST' });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'tasks.error_create'));
+                return;
+            }
+            const created = await response.json();
+            if (!created.length) {
+                alert(this.t('links.no_new_todos'));
             }
+            this.loadTodos();
+            this.renderNoteTodos(note.id);
         } catch (error) {
-            alert('Network error');
+            alert(this.t('common.network_error'));
+        }
+    }
+
+    async renderBacklinks(id) {
+        const container = document.getElementById('noteBacklinks');
+        container.replaceChildren();
+        try {
+            const response = await fetch(`/api/notes/${id}/backlinks`);
+            if (!response.ok || this.currentEditingNote?.id !== id) return;
+            const notes = await response.json();
+            if (!notes.length) return;
+
+            const label = document.createElement('span');
+            label.textContent = this.t('links.backlinks');
+            container.append(label, ...notes.map(note => {
+                const link = document.createElement('button');
+                link.className = 'filter-btn';
+                link.textContent = note.title;
+                link.onclick = () => this.openNote(note.id);
+                return link;
+            }));
+        } catch (error) {
+            console.error('Error loading backlinks:', error);
         }
     }
 
@@ -373,27 +1606,34 @@ class TaskFlowApp {
     async addNote() {
         const title = document.getElementById('noteTitleInput').value;
         const content = document.getElementById('noteContentInput').value;
+        const templateID = Number(document.getElementById('noteTemplateSelect').value) || 0;
 
-        if (!title) return;
+        // С шаблоном заголовок можно не вводить — его подставит сервер
+        if (!title && !templateID) return;
 
         try {
-            await fetch('/api/notes', {
+            const response = await fetch('/api/notes', {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ title, content })
+                body: JSON.stringify({ title, content, folder_id: this.folderID(), template_id: templateID, tz: this.timeZone })
             });
+            if (!response.ok) {
+                alert(await this.errorMessage(response, 'notes.error_create'));
+                return;
+            }
 
             this.hideModals();
             document.getElementById('noteTitleInput').value = '';
             document.getElementById('noteContentInput').value = '';
+            document.getElementById('noteTemplateSelect').value = '';
             this.loadNotes();
         } catch (error) {
-            alert('Error creating note');
+            alert(this.t('notes.error_create'));
         }
     }
 
     async deleteNote(id) {
-        if (!confirm('Are you sure you want to delete this note?')) return;
+        if (!confirm(this.t('notes.confirm_delete'))) return;
         
         try {
             await fetch(`/api/notes/${id}`, {
@@ -401,13 +1641,13 @@ class TaskFlowApp {
             });
             this.loadNotes();
         } catch (error) {
-            alert('Error deleting note');
+            alert(this.t('notes.error_delete'));
         }
     }
 
     // Управление аккаунтом
     async deleteAccount() {
-        if (!confirm('Are you sure? This action cannot be undone!')) return;
+        if (!confirm(this.t('account.confirm_delete'))) return;
 
         try {
             await fetch('/api/account', {
@@ -417,7 +1657,7 @@ class TaskFlowApp {
             this.hideModals();
             this.handleLogout();
         } catch (error) {
-            alert('Error deleting account');
+            alert(this.t('account.error_delete'));
         }
     }
 }
@@ -438,6 +1678,7 @@ function showAddTaskModal() {
 }
 
 function showAddNoteModal() {
+    app.loadTemplates();
     document.getElementById('addNoteModal').classList.add('active');
 }
 
@@ -489,10 +1730,94 @@ function showViewNoteModal() {
     app.showViewNoteModal();
 }
 
+function showNoteHistory() {
+    app.showNoteHistory();
+}
+
 function toggleMobileMenu() {
     app.toggleMobileMenu();
 }
 
+function setLanguage(lang) {
+    app.setLanguage(lang);
+}
+
+function setDueFilter(due) {
+    app.setDueFilter(due);
+}
+
+function setTaskSort(sortBy) {
+    app.setTaskSort(sortBy);
+}
+
+function setTaskList(listID) {
+    app.setTaskList(listID);
+}
+
+function addList() {
+    app.addList();
+}
+
+function toggleArchive() {
+    app.toggleArchive();
+}
+
+function toggleNotesArchive() {
+    app.toggleNotesArchive();
+}
+
+function uploadAttachment(input) {
+    app.uploadAttachment(input);
+}
+
+function createTodosFromNote() {
+    app.createTodosFromNote();
+}
+
+function setNoteFolder(folderID) {
+    app.setNoteFolder(folderID);
+}
+
+function addFolder() {
+    app.addFolder();
+}
+
+function renameFolder() {
+    app.renameFolder();
+}
+
+function deleteFolder() {
+    app.deleteFolder();
+}
+
+function moveNoteToFolder(folderID) {
+    app.moveNoteToFolder(folderID);
+}
+
+function emptyTrash() {
+    app.emptyTrash();
+}
+
+function saveNoteAsTemplate() {
+    app.saveNoteAsTemplate();
+}
+
+function deleteTemplate() {
+    app.deleteTemplate();
+}
+
+function searchAll(query) {
+    app.searchAll(query);
+}
+
+function setTaskTag(tagID) {
+    app.setTaskTag(tagID);
+}
+
+function addTag() {
+    app.addTag();
+}
+
 // Инициализация приложения
 const app = new TaskFlowApp();
 
@@ -514,4 +1839,25 @@ window.deleteAccount = deleteAccount;
 window.enableNoteEdit = enableNoteEdit;
 window.saveNoteEdit = saveNoteEdit;
 window.showViewNoteModal = showViewNoteModal;
-window.toggleMobileMenu = toggleMobileMenu;
\ No newline at end of file
+window.showNoteHistory = showNoteHistory;
+window.toggleMobileMenu = toggleMobileMenu;
+window.setLanguage = setLanguage;
+window.setDueFilter = setDueFilter;
+window.setTaskSort = setTaskSort;
+window.setTaskList = setTaskList;
+window.addList = addList;
+window.setTaskTag = setTaskTag;
+window.searchAll = searchAll;
+window.emptyTrash = emptyTrash;
+window.saveNoteAsTemplate = saveNoteAsTemplate;
+window.deleteTemplate = deleteTemplate;
+window.setNoteFolder = setNoteFolder;
+window.addFolder = addFolder;
+window.renameFolder = renameFolder;
+window.deleteFolder = deleteFolder;
+window.moveNoteToFolder = moveNoteToFolder;
+window.createTodosFromNote = createTodosFromNote;
+window.uploadAttachment = uploadAttachment;
+window.toggleArchive = toggleArchive;
+window.toggleNotesArchive = toggleNotesArchive;
+window.addTag = addTag;
\ No newline at end of file